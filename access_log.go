@@ -0,0 +1,154 @@
+package httprouter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// entry of access log
+type AccessLog struct {
+	Time   time.Time
+	Method string
+	// raw path of request
+	Path string
+	// pattern of the matched route like /users/:id, empty for files and not found
+	Route    string
+	Proto    string
+	Status   int
+	Bytes    int64
+	Latency  time.Duration
+	RemoteIP string
+	// id set by RequestID, or X-Request-ID of request without it
+	RequestID string
+}
+
+// where access logs go
+type AccessLogSink func(*AccessLog)
+
+// middleware logging every request to sink once the response is flushed, use
+// it with router.Use so files and not found are logged too
+//
+//	router.Use(AccessLogger(SlogSink(slog.Default())))
+func AccessLogger(sink AccessLogSink) MiddlewareFunc {
+	return func(next HttpHandler) HttpHandler {
+		return func(r *Response, req *Request) {
+			start := time.Now()
+			r.OnFlush(func() {
+				entry := &AccessLog{
+					Time:      start,
+					Method:    req.Method,
+					Path:      req.URL.RequestURI(),
+					Proto:     req.Proto,
+					Status:    r.StatusCode(),
+					Bytes:     r.BytesWritten(),
+					Latency:   time.Since(start),
+					RemoteIP:  remoteIP(req.RemoteAddr),
+					RequestID: req.RequestID(),
+				}
+				if entry.RequestID == "" {
+					entry.RequestID = req.Header.Get("X-Request-ID")
+				}
+				if route := req.Route(); route != nil {
+					entry.Route = route.Path()
+				}
+				sink(entry)
+			})
+			next(r, req)
+		}
+	}
+}
+
+// ip of remote address without port
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// sink writing to w in common log format, followed by latency, route and request id
+//
+//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET /users/1 HTTP/1.1" 200 2326 0.000512 "/users/:id" "5f2b..."
+func CommonLogSink(w io.Writer) AccessLogSink {
+	var mu sync.Mutex
+	return func(l *AccessLog) {
+		bytes := "-"
+		if l.Bytes > 0 {
+			bytes = fmt.Sprint(l.Bytes)
+		}
+		line := fmt.Sprintf("%s - - [%s] %q %d %s %.6f %q %q\n",
+			orDash(l.RemoteIP), l.Time.Format("02/Jan/2006:15:04:05 -0700"),
+			l.Method+" "+l.Path+" "+l.Proto, l.Status, bytes,
+			l.Latency.Seconds(), orDash(l.Route), orDash(l.RequestID))
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// access log in json
+type accessLogJSON struct {
+	Time      string  `json:"time"`
+	Method    string  `json:"method"`
+	Path      string  `json:"path"`
+	Route     string  `json:"route,omitempty"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int64   `json:"bytes"`
+	LatencyMS float64 `json:"latency_ms"`
+	RemoteIP  string  `json:"remote_ip"`
+	RequestID string  `json:"request_id,omitempty"`
+}
+
+// sink writing to w a json object per line
+func JSONLogSink(w io.Writer) AccessLogSink {
+	var mu sync.Mutex
+	return func(l *AccessLog) {
+		line, err := json.Marshal(accessLogJSON{
+			l.Time.Format(time.RFC3339Nano), l.Method, l.Path, l.Route, l.Proto, l.Status,
+			l.Bytes, float64(l.Latency) / float64(time.Millisecond), l.RemoteIP, l.RequestID,
+		})
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(line, '\n'))
+	}
+}
+
+// sink logging to logger with message "access", 5xx are logged as error and 4xx as warn
+func SlogSink(logger *slog.Logger) AccessLogSink {
+	return func(l *AccessLog) {
+		level := slog.LevelInfo
+		if l.Status >= 500 {
+			level = slog.LevelError
+		} else if l.Status >= 400 {
+			level = slog.LevelWarn
+		}
+		logger.LogAttrs(context.Background(), level, "access",
+			slog.String("method", l.Method),
+			slog.String("path", l.Path),
+			slog.String("route", l.Route),
+			slog.String("proto", l.Proto),
+			slog.Int("status", l.Status),
+			slog.Int64("bytes", l.Bytes),
+			slog.Duration("latency", l.Latency),
+			slog.String("remote_ip", l.RemoteIP),
+			slog.String("request_id", l.RequestID),
+		)
+	}
+}
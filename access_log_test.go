@@ -0,0 +1,79 @@
+package httprouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	. "testing"
+	"testing/fstest"
+)
+
+func TestAccessLogger(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API, PATHFILE}
+	r.FS = fstest.MapFS{"v.txt": {Data: []byte("0123456789")}}
+	logs := []*AccessLog{}
+	r.Use(AccessLogger(func(l *AccessLog) {
+		logs = append(logs, l)
+	}))
+	r.OnGet("/users/:id", func(w *Response, req *Request) {
+		w.WithString("user " + req.Param("id"))
+	})
+
+	req := httptest.NewRequest("GET", "/users/7?full=1", nil)
+	req.RemoteAddr = "10.0.0.1:5678"
+	req.Header.Set("X-Request-ID", "abc")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), getRequest("POST", "/nope"))
+	serveRequest(r, "GET", "/v.txt", http.Header{"Range": {"bytes=2-4"}})
+	serveRequest(r, "GET", "/v.txt", http.Header{"Range": {"bytes=50-"}})
+
+	if len(logs) != 4 {
+		t.Fatalf("expect 4 logs, got %d", len(logs))
+	}
+	l := logs[0]
+	if l.Method != "GET" || l.Path != "/users/7?full=1" || l.Route != "/users/:id" || l.Status != 200 ||
+		l.Bytes != int64(len("user 7")) || l.RemoteIP != "10.0.0.1" || l.RequestID != "abc" || l.Latency <= 0 {
+		t.Errorf("route log: got %+v", l)
+	}
+	if l := logs[1]; l.Route != "" || l.Status != http.StatusNotFound || l.Bytes != int64(len("Not Found")) {
+		t.Errorf("not found log: got %+v", l)
+	}
+	if l := logs[2]; l.Status != http.StatusPartialContent || l.Bytes != 3 {
+		t.Errorf("range log: got %+v", l)
+	}
+	if l := logs[3]; l.Status != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable range log: got %+v", l)
+	}
+}
+
+func TestAccessLogSinks(t *T) {
+	l := &AccessLog{
+		Method: "GET", Path: "/users/7", Route: "/users/:id", Proto: "HTTP/1.1",
+		Status: 404, Bytes: 0, RemoteIP: "10.0.0.1", RequestID: "abc",
+	}
+
+	var buf bytes.Buffer
+	CommonLogSink(&buf)(l)
+	if line := buf.String(); !strings.HasPrefix(line, `10.0.0.1 - - [`) ||
+		!strings.Contains(line, `"GET /users/7 HTTP/1.1" 404 - `) || !strings.HasSuffix(line, `"/users/:id" "abc"`+"\n") {
+		t.Errorf("common log: got %q", line)
+	}
+
+	buf.Reset()
+	JSONLogSink(&buf)(l)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry["route"] != "/users/:id" || entry["status"] != 404.0 || entry["request_id"] != "abc" {
+		t.Errorf("json log: got %s, %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	SlogSink(slog.New(slog.NewJSONHandler(&buf, nil)))(l)
+	entry = nil
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil || entry["level"] != "WARN" || entry["msg"] != "access" || entry["route"] != "/users/:id" {
+		t.Errorf("slog: got %s, %v", buf.String(), err)
+	}
+}
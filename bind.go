@@ -0,0 +1,276 @@
+package httprouter
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// memory used to parse multipart body, the rest of file parts are stored on disk
+const bindMultipartMemory = 32 << 20
+
+var (
+	// dst of Bind is not a pointer to struct
+	ErrBindTarget = errors.New("bind target must be a non-nil pointer to struct")
+	// Content-Type of body can't be decoded by Bind
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+)
+
+// error of binding a field
+type FieldError struct {
+	// name of the struct field, nested fields are joined by '.'
+	Field string
+	// where the value is from, one of form, query, param, json and xml
+	Source string
+	// key in the source
+	Key string
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (%s %q): %s", e.Field, e.Source, e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// errors of binding fields
+type FieldErrors []*FieldError
+
+func (errs FieldErrors) Error() string {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// sources of tagged fields, in order of binding, later ones override earlier ones
+var bindSources = []string{"form", "query", "param"}
+
+// bind request into the struct dst points to. body is decoded by Content-Type
+// as json, xml, urlencoded or multipart form, then fields tagged with form,
+// query and param are set from form values, query string and route params
+//
+//	type listArticles struct {
+//		UserID   int64    `param:"user_id"`
+//		Page     int      `query:"page"`
+//		Keywords []string `form:"keywords"`
+//	}
+//
+// fields failed to bind are reported with FieldErrors
+func (req *Request) Bind(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrBindTarget
+	}
+	errs := FieldErrors{}
+	if err := req.bindBody(dst); err != nil {
+		var fe *FieldError
+		if !errors.As(err, &fe) {
+			return err
+		}
+		errs = append(errs, fe)
+	}
+	for _, source := range bindSources {
+		values, err := req.bindValues(source)
+		if err != nil {
+			return err
+		}
+		var files map[string][]*multipart.FileHeader
+		if source == "form" && req.MultipartForm != nil {
+			files = req.MultipartForm.File
+		}
+		errs = append(errs, bindFields(rv.Elem(), source, values, files, "")...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// values of the source, form values are parsed from body if needed
+func (req *Request) bindValues(source string) (map[string][]string, error) {
+	switch source {
+	case "form":
+		form, err := req.form()
+		if err != nil {
+			return nil, fmt.Errorf("parse form body: %w", err)
+		}
+		return form, nil
+	case "query":
+		return req.URL.Query(), nil
+	case "param":
+		values := make(map[string][]string, len(req.params))
+		for k, v := range req.params {
+			values[k] = []string{v}
+		}
+		return values, nil
+	}
+	return nil, nil
+}
+
+func isMultipart(req *http.Request) bool {
+	ct, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return ct == "multipart/form-data"
+}
+
+// decode json or xml body into dst, form bodies are left for bindValues
+func (req *Request) bindBody(dst interface{}) error {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return nil
+	}
+	header := req.Header.Get("Content-Type")
+	if header == "" {
+		return nil
+	}
+	ct, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, header)
+	}
+	switch {
+	case ct == "application/json" || strings.HasSuffix(ct, "+json"):
+		err := json.NewDecoder(req.Body).Decode(dst)
+		var te *json.UnmarshalTypeError
+		if errors.As(err, &te) {
+			return &FieldError{te.Field, "json", te.Field, fmt.Errorf("cannot use %s as %s", te.Value, te.Type)}
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("decode json body: %w", err)
+		}
+	case ct == "application/xml" || ct == "text/xml" || strings.HasSuffix(ct, "+xml"):
+		if err := xml.NewDecoder(req.Body).Decode(dst); err != nil && err != io.EOF {
+			return fmt.Errorf("decode xml body: %w", err)
+		}
+	case ct == "application/x-www-form-urlencoded" || ct == "multipart/form-data":
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedContentType, ct)
+	}
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// set fields of v tagged with source from values and files, untagged struct fields are bound recursively
+func bindFields(v reflect.Value, source string, values map[string][]string, files map[string][]*multipart.FileHeader, prefix string) FieldErrors {
+	errs := FieldErrors{}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := v.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		key, tagged := field.Tag.Lookup(source)
+		if key == "-" {
+			continue
+		}
+		if !tagged {
+			if fv.Kind() == reflect.Struct && !reflect.PtrTo(fv.Type()).Implements(textUnmarshalerType) {
+				errs = append(errs, bindFields(fv, source, values, files, prefix+field.Name+".")...)
+			}
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+		if ft := fv.Type(); ft == fileHeaderType || ft == reflect.SliceOf(fileHeaderType) {
+			if fhs := files[key]; len(fhs) > 0 && ft == fileHeaderType {
+				fv.Set(reflect.ValueOf(fhs[0]))
+			} else if len(fhs) > 0 {
+				fv.Set(reflect.ValueOf(fhs))
+			}
+			continue
+		}
+		vals, ok := values[key]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		if err := setField(fv, vals); err != nil {
+			errs = append(errs, &FieldError{prefix + field.Name, source, key, err})
+		}
+	}
+	return errs
+}
+
+// set v from string values, slices take all values and others the first one
+func setField(v reflect.Value, vals []string) error {
+	if v.Kind() == reflect.Slice && !v.Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(vals), len(vals))
+		for i, val := range vals {
+			if err := setValue(slice.Index(i), val); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, vals[0])
+}
+
+func setValue(v reflect.Value, val string) error {
+	if v.Kind() == reflect.Ptr {
+		if val == "" {
+			return nil
+		}
+		ptr := reflect.New(v.Type().Elem())
+		if err := setValue(ptr.Elem(), val); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val))
+	}
+	if val == "" && v.Kind() != reflect.String {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			if val != "on" {
+				return err
+			}
+			b = true
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(val, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package httprouter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// content coding used to compress response body, implement it to plug other
+// codings like brotli into Compressor.Encoders
+type Encoder interface {
+	// content coding token, the value of Content-Encoding
+	Encoding() string
+	// wrap w, bytes written are compressed into w, and closing flush the rest
+	NewWriter(w io.Writer) io.WriteCloser
+}
+
+type gzipEncoder struct {
+	level int
+}
+
+// gzip encoder with compress level, see compress/gzip
+func GzipEncoder(level int) Encoder {
+	return &gzipEncoder{level}
+}
+
+func (e *gzipEncoder) Encoding() string {
+	return "gzip"
+}
+
+func (e *gzipEncoder) NewWriter(w io.Writer) io.WriteCloser {
+	zw, err := gzip.NewWriterLevel(w, e.level)
+	if err != nil {
+		return gzip.NewWriter(w)
+	}
+	return zw
+}
+
+type deflateEncoder struct {
+	level int
+}
+
+// deflate encoder with compress level. http deflate coding is the zlib format, see compress/zlib
+func DeflateEncoder(level int) Encoder {
+	return &deflateEncoder{level}
+}
+
+func (e *deflateEncoder) Encoding() string {
+	return "deflate"
+}
+
+func (e *deflateEncoder) NewWriter(w io.Writer) io.WriteCloser {
+	zw, err := zlib.NewWriterLevel(w, e.level)
+	if err != nil {
+		return zlib.NewWriter(w)
+	}
+	return zw
+}
+
+// compress response body according to the Accept-Encoding of request
+type Compressor struct {
+	// encoders in preference order, used when client accept them with the same q-value
+	Encoders []Encoder
+	// body shorter than MinSize is sent as is
+	MinSize int
+	// content types already compressed, an item ending with '/' match the whole type
+	SkipTypes []string
+}
+
+// new compressor with gzip and deflate, skipping bodies less than 1k and common compressed types
+func NewCompressor() *Compressor {
+	return &Compressor{
+		Encoders: []Encoder{
+			GzipEncoder(gzip.DefaultCompression),
+			DeflateEncoder(zlib.DefaultCompression),
+		},
+		MinSize: 1024,
+		SkipTypes: []string{
+			"image/", "video/", "audio/", "font/woff", "font/woff2",
+			"application/zip", "application/gzip", "application/x-gzip",
+			"application/x-bzip2", "application/x-xz", "application/x-7z-compressed",
+			"application/x-rar-compressed", "application/vnd.rar", "application/wasm",
+		},
+	}
+}
+
+var defaultCompressor = NewCompressor()
+
+// body with the headers is worth compressing
+func (c *Compressor) compressible(header http.Header) bool {
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	ct, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	// xml based types like image/svg+xml are text
+	if strings.HasSuffix(ct, "+xml") {
+		return true
+	}
+	for _, skip := range c.SkipTypes {
+		if ct == skip || strings.HasSuffix(skip, "/") && strings.HasPrefix(ct, skip) {
+			return false
+		}
+	}
+	return true
+}
+
+// pick the encoder client prefer most, nil when client accept none of them
+func (c *Compressor) negotiate(acceptEncoding string) Encoder {
+	accepts := parseAcceptEncoding(acceptEncoding)
+	var chosen Encoder
+	best := 0.0
+	for _, enc := range c.Encoders {
+		q, ok := accepts[enc.Encoding()]
+		if !ok {
+			q = accepts["*"]
+		}
+		if q > best {
+			chosen, best = enc, q
+		}
+	}
+	return chosen
+}
+
+// write status and body to w, compressed if client accept one of the encoders
+// and the body is not shorter than MinSize. response to HEAD gets the same
+// headers as GET but no body
+func (c *Compressor) write(w http.ResponseWriter, req *http.Request, status int, body io.Reader) error {
+	noBody := req.Method == http.MethodHead
+	enc := c.negotiate(req.Header.Get("Accept-Encoding"))
+	if enc == nil {
+		w.WriteHeader(status)
+		if noBody {
+			return nil
+		}
+		_, err := io.Copy(w, body)
+		return err
+	}
+	head := make([]byte, c.MinSize)
+	n, err := io.ReadFull(body, head)
+	head = head[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		w.WriteHeader(status)
+		if noBody {
+			return nil
+		}
+		_, err = w.Write(head)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	// net/http doesn't sniff Content-Type of encoded body, sniff it from the
+	// uncompressed head, unless the type is suppressed by a nil header value
+	if _, ok := w.Header()["Content-Type"]; !ok {
+		w.Header().Set("Content-Type", http.DetectContentType(head))
+	}
+	w.Header().Set("Content-Encoding", enc.Encoding())
+	w.Header().Del("Content-Length")
+	// ranges are served from the identity body, so the compressed one neither
+	// accept ranges nor share a strong ETag that If-Range would match
+	w.Header().Del("Accept-Ranges")
+	if tag := w.Header().Get("ETag"); strings.HasPrefix(tag, `"`) {
+		w.Header().Set("ETag", "W/"+tag)
+	}
+	w.WriteHeader(status)
+	if noBody {
+		return nil
+	}
+	zw := enc.NewWriter(w)
+	if _, err := io.Copy(zw, io.MultiReader(bytes.NewReader(head), body)); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+// parse Accept-Encoding into coding and q-value, codings without q-value get 1
+func parseAcceptEncoding(header string) map[string]float64 {
+	accepts := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 || strings.ToLower(strings.TrimSpace(kv[0])) != "q" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+			if err != nil || v < 0 || v > 1 {
+				v = 0
+			}
+			q = v
+		}
+		accepts[coding] = q
+	}
+	return accepts
+}
+
+// add token to the Vary header unless it's already there
+func addVary(header http.Header, token string) {
+	for _, v := range header.Values("Vary") {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", token)
+}
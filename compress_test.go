@@ -0,0 +1,153 @@
+package httprouter
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	. "testing"
+	"testing/fstest"
+)
+
+func TestNegotiate(t *T) {
+	c := NewCompressor()
+	cases := map[string]string{
+		"":                          "",
+		"gzip":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, deflate;q=0":     "",
+		"br, *;q=0.1":               "gzip",
+		"identity":                  "",
+		"GZIP ; q=0.8, deflate;q=1": "deflate",
+	}
+	for header, expect := range cases {
+		enc := c.negotiate(header)
+		got := ""
+		if enc != nil {
+			got = enc.Encoding()
+		}
+		if got != expect {
+			t.Errorf("%q: expect %q, got %q", header, expect, got)
+		}
+	}
+}
+
+func TestFlushCompress(t *T) {
+	long := strings.Repeat("hello world ", 200)
+	flush := func(acceptEncoding, contentType, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := getRequest("GET", "/")
+		req.Header = map[string][]string{"Accept-Encoding": {acceptEncoding}}
+		r := NewResponse(rec)
+		r.WithHeader("Content-Type", contentType).WithBody(strings.NewReader(body))
+		r.Flush(req)
+		return rec
+	}
+
+	rec := flush("gzip", "text/plain", long)
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("gzip: got %v", rec.Header())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadAll(zr); string(b) != long {
+		t.Error("gzip: body not match")
+	}
+
+	rec = flush("", "text/plain", long)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != long {
+		t.Errorf("no accept encoding: got %v", rec.Header())
+	}
+
+	rec = flush("gzip", "text/plain", "short")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "short" {
+		t.Errorf("short body: got %v", rec.Header())
+	}
+
+	rec = flush("gzip", "image/png", long)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("compressed type: got %v", rec.Header())
+	}
+}
+
+func TestCompressThenResume(t *T) {
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	long := strings.Repeat("hello world ", 200)
+	r.FS = fstest.MapFS{"e.txt": {Data: []byte(long)}}
+
+	rec := serveRequest(r, "GET", "/e.txt", http.Header{"Accept-Encoding": {"gzip"}})
+	tag := rec.Header().Get("ETag")
+	if rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Accept-Ranges") != "" || !strings.HasPrefix(tag, `W/"`) {
+		t.Fatalf("compressed: got %v", rec.Header())
+	}
+
+	// resuming the compressed download must not get identity bytes
+	rec = serveRequest(r, "GET", "/e.txt", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=100-"}, "If-Range": {tag}})
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Range") != "" {
+		t.Errorf("resume with compressed etag: got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = serveRequest(r, "GET", "/e.txt", nil)
+	identity := rec.Header().Get("ETag")
+	if rec.Header().Get("Accept-Ranges") != "bytes" || !strings.HasPrefix(identity, `"`) {
+		t.Fatalf("identity: got %v", rec.Header())
+	}
+	rec = serveRequest(r, "GET", "/e.txt", http.Header{"Range": {"bytes=100-"}, "If-Range": {identity}})
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != long[100:] {
+		t.Errorf("resume with identity etag: got %d", rec.Code)
+	}
+}
+
+func TestHeadCompress(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	long := strings.Repeat("hello world ", 200)
+	r.OnGet("/long", func(w *Response, req *Request) {
+		w.WithString(long)
+	})
+	r.OnGet("/short", func(w *Response, req *Request) {
+		w.WithString("short")
+	})
+	for _, p := range []string{"/long", "/short"} {
+		get := serveRequest(r, "GET", p, http.Header{"Accept-Encoding": {"gzip"}})
+		head := serveRequest(r, "HEAD", p, http.Header{"Accept-Encoding": {"gzip"}})
+		for _, key := range []string{"Content-Encoding", "Vary", "Content-Type"} {
+			if get.Header().Get(key) != head.Header().Get(key) {
+				t.Errorf("%s %s: get %q, head %q", p, key, get.Header().Get(key), head.Header().Get(key))
+			}
+		}
+		if head.Code != 200 || head.Body.Len() != 0 {
+			t.Errorf("%s head: got %d, %d bytes", p, head.Code, head.Body.Len())
+		}
+	}
+}
+
+func TestCompressSniff(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	html := "<!DOCTYPE html><html>" + strings.Repeat("<p>hello</p>", 200) + "</html>"
+	r.OnGet("/page", func(w *Response, req *Request) {
+		w.WithBody(strings.NewReader(html))
+	})
+	// the recorder sniffs no matter Content-Encoding, so use a real server
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, encoding := range []string{"gzip", "identity"} {
+		req, _ := http.NewRequest("GET", srv.URL+"/page", nil)
+		req.Header.Set("Accept-Encoding", encoding)
+		res, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if ct := res.Header.Get("Content-Type"); ct != "text/html; charset=utf-8" {
+			t.Errorf("%s: got Content-Type %q, Content-Encoding %q", encoding, ct, res.Header.Get("Content-Encoding"))
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package httprouter
+
+import (
+	"context"
+)
+
+// typed key of request scoped values
+//
+//	var userKey = httprouter.NewContextKey[*User]("user")
+//	userKey.Set(req, user)           // in middleware
+//	user, ok := userKey.Get(req)     // in handler
+type ContextKey[T any] struct {
+	name string
+}
+
+func NewContextKey[T any](name string) *ContextKey[T] {
+	return &ContextKey[T]{name}
+}
+
+func (k *ContextKey[T]) String() string {
+	return "httprouter context key " + k.name
+}
+
+// set value of the key into the request context
+func (k *ContextKey[T]) Set(req *Request, val T) {
+	req.WithValue(k, val)
+}
+
+// value of the key in the request context
+func (k *ContextKey[T]) Get(req *Request) (T, bool) {
+	return k.From(req.Context())
+}
+
+// value of the key in ctx
+func (k *ContextKey[T]) From(ctx context.Context) (T, bool) {
+	val, ok := ctx.Value(k).(T)
+	return val, ok
+}
@@ -0,0 +1,151 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// entry of directory listing
+type dirEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	IsDir   bool      `json:"is_dir"`
+}
+
+var dirTemplate = template.Must(template.New("dir").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th><a href="?sort=name&order={{.Order}}">Name</a></th><th><a href="?sort=size&order={{.Order}}">Size</a></th><th><a href="?sort=time&order={{.Order}}">Modified</a></th></tr>
+{{range .Entries}}<tr><td><a href="{{.Href}}">{{.Name}}</a></td><td>{{if not .IsDir}}{{.Size}}{{end}}</td><td>{{.ModTime.UTC.Format "2006-01-02 15:04:05"}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// serve index file of directory name, or list it when DirListing is enabled.
+// a path without trailing slash is redirected to the one with it
+func (router *Router) tryDir(r *Response, req *Request, fsys fs.FS, name string) bool {
+	index := ""
+	var stat fs.FileInfo
+	for _, file := range router.IndexFiles {
+		p := path.Join(name, file)
+		if s, err := fs.Stat(fsys, p); err == nil && !s.IsDir() {
+			index, stat = p, s
+			break
+		}
+	}
+	if index == "" && !router.DirListing {
+		return false
+	}
+	if !strings.HasSuffix(req.URL.Path, "/") {
+		// relative location, so "//host" like paths never redirect to other hosts
+		location := (&url.URL{Path: path.Base(req.URL.Path) + "/"}).String()
+		if req.URL.RawQuery != "" {
+			location += "?" + req.URL.RawQuery
+		}
+		r.WithStatus(http.StatusMovedPermanently).WithHeader("Location", location)
+		return true
+	}
+	if index != "" {
+		return router.serveFile(r, req, fsys, index, stat, router.BeforePathFile)
+	}
+	return router.listDir(r, req, fsys, name)
+}
+
+// list directory as html, or json when asked by ?format=json or Accept header.
+// entries are sorted by ?sort=name|size|time and ?order=asc|desc, directories first
+func (router *Router) listDir(r *Response, req *Request, fsys fs.FS, name string) bool {
+	items, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return false
+	}
+	entries := []dirEntry{}
+	for _, item := range items {
+		if strings.HasPrefix(item.Name(), ".") && !router.ServeHidden {
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, dirEntry{item.Name(), info.Size(), info.ModTime(), item.IsDir()})
+	}
+	query := req.URL.Query()
+	sortEntries(entries, query.Get("sort"), query.Get("order") == "desc")
+
+	r.WithStatus(http.StatusOK).WithHeader("Cache-Control", "no-cache")
+	if query.Get("format") == "json" || strings.Contains(req.Header.Get("Accept"), "application/json") {
+		b, err := json.Marshal(entries)
+		if err != nil {
+			r.InternalError(err)
+			return true
+		}
+		r.WithHeader("Content-Type", "application/json")
+		r.WithBody(strings.NewReader(string(b)))
+		return true
+	}
+	type htmlEntry struct {
+		dirEntry
+		Href string
+	}
+	data := struct {
+		Path    string
+		Order   string
+		Entries []htmlEntry
+	}{Path: req.URL.Path, Order: "asc"}
+	if query.Get("order") != "desc" {
+		data.Order = "desc"
+	}
+	for _, entry := range entries {
+		href := (&url.URL{Path: entry.Name}).String()
+		if entry.IsDir {
+			href += "/"
+		}
+		data.Entries = append(data.Entries, htmlEntry{entry, href})
+	}
+	var buf strings.Builder
+	if err := dirTemplate.Execute(&buf, data); err != nil {
+		r.InternalError(err)
+		return true
+	}
+	r.WithHeader("Content-Type", "text/html; charset=utf-8")
+	r.WithBody(strings.NewReader(buf.String()))
+	return true
+}
+
+func sortEntries(entries []dirEntry, by string, desc bool) {
+	less := func(a, b dirEntry) bool {
+		return a.Name < b.Name
+	}
+	switch by {
+	case "size":
+		less = func(a, b dirEntry) bool {
+			return a.Size < b.Size || a.Size == b.Size && a.Name < b.Name
+		}
+	case "time":
+		less = func(a, b dirEntry) bool {
+			return a.ModTime.Before(b.ModTime) || a.ModTime.Equal(b.ModTime) && a.Name < b.Name
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
+		}
+		if desc {
+			return less(b, a)
+		}
+		return less(a, b)
+	})
+}
@@ -0,0 +1,165 @@
+package httprouter
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keys nested deeper are left out of the nested form
+const maxFormDepth = 32
+
+// parse form values with bracket keys like filter[status][]=open into nested
+// values. a value is a string, []interface{} for keys ending with [], or
+// map[string]interface{} for other bracket segments. keys are parsed in
+// sorted order, and a later key replaces a value of different shape
+func parseNestedForm(form url.Values) map[string]interface{} {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	nested := make(map[string]interface{})
+	for _, key := range keys {
+		vals := form[key]
+		if len(vals) == 0 {
+			continue
+		}
+		segs, ok := splitFormKey(key)
+		if !ok {
+			segs = []string{key}
+		}
+		if len(segs) > maxFormDepth {
+			continue
+		}
+		setNested(nested, segs, vals)
+	}
+	return nested
+}
+
+// split a[b][][c] into a, b, "", c. key is not nested when the brackets are
+// malformed or the name before them is empty
+func splitFormKey(key string) ([]string, bool) {
+	i := strings.IndexByte(key, '[')
+	if i <= 0 {
+		return nil, false
+	}
+	segs := []string{key[:i]}
+	rest := key[i:]
+	for rest != "" {
+		if rest[0] != '[' {
+			return nil, false
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return nil, false
+		}
+		seg := rest[1:end]
+		if strings.IndexByte(seg, '[') >= 0 {
+			return nil, false
+		}
+		segs = append(segs, seg)
+		rest = rest[end+1:]
+	}
+	return segs, true
+}
+
+// set vals at the path of segs in m. a leaf takes the first value, or all
+// values when it ends with [], and [] in the middle appends new items
+func setNested(m map[string]interface{}, segs []string, vals []string) {
+	name, next := segs[0], segs[1:]
+	if len(next) == 0 {
+		m[name] = vals[0]
+		return
+	}
+	if next[0] == "" {
+		items, _ := m[name].([]interface{})
+		if len(next) == 1 {
+			for _, val := range vals {
+				items = append(items, val)
+			}
+		} else {
+			// each value of a repeated key like items[][id] starts a new item
+			for _, val := range vals {
+				child := make(map[string]interface{})
+				setNested(child, append([]string{"0"}, next[1:]...), []string{val})
+				items = append(items, child["0"])
+			}
+		}
+		m[name] = items
+		return
+	}
+	child, ok := m[name].(map[string]interface{})
+	if !ok {
+		child = make(map[string]interface{})
+		m[name] = child
+	}
+	setNested(child, next, vals)
+}
+
+// form of request, body is parsed if needed. multipart body is parsed even
+// when ParseForm has been called, which leaves the multipart fields out
+func (req *Request) form() (url.Values, error) {
+	if isMultipart(req.Request) {
+		if req.MultipartForm == nil {
+			if err := req.ParseMultipartForm(bindMultipartMemory); err != nil {
+				return req.Form, err
+			}
+		}
+	} else if req.Form == nil {
+		if err := req.ParseForm(); err != nil {
+			return req.Form, err
+		}
+	}
+	return req.Form, nil
+}
+
+// form values parsed into nested values, cached in request
+func (req *Request) nestedForm() map[string]interface{} {
+	if req.nested == nil {
+		form, _ := req.form()
+		req.nested = parseNestedForm(form)
+	}
+	return req.nested
+}
+
+// read form field with bracket keys as nested values, for
+// filter[status][]=open&filter[user][name]=bob it returns
+//
+//	map[string]interface{}{
+//		"status": []interface{}{"open"},
+//		"user":   map[string]interface{}{"name": "bob"},
+//	}
+//
+// a field without brackets is a string, and nil when the field is not found
+func (req *Request) FormNested(fieldname string) interface{} {
+	return req.nestedForm()[fieldname]
+}
+
+// string items of a nested list, items of key[0], key[1] are in order of index
+func nestedStrings(v interface{}) []string {
+	r := []string{}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				r = append(r, s)
+			}
+		}
+	case map[string]interface{}:
+		indexes := []int{}
+		for k := range v {
+			if i, err := strconv.Atoi(k); err == nil && i >= 0 && strconv.Itoa(i) == k {
+				if _, ok := v[k].(string); ok {
+					indexes = append(indexes, i)
+				}
+			}
+		}
+		sort.Ints(indexes)
+		for _, i := range indexes {
+			r = append(r, v[strconv.Itoa(i)].(string))
+		}
+	}
+	return r
+}
@@ -1,5 +1,3 @@
 module github.com/yang-zzhong/go-httprouter
 
-go 1.13
-
-require github.com/yang-zzhong/go-restfulpath v0.2.0
+go 1.21
@@ -0,0 +1,96 @@
+package httprouter
+
+import (
+	"net/http"
+)
+
+// a scope of routes sharing the same prefix and middleware. routes registered
+// through a group get the prefix and middleware of the group and all its parents
+type RouteGroup struct {
+	router *Router
+	prefix string
+	ms     []Mw
+	// NotFound wrapped by ms, composed on first use
+	notFound lazyHandler
+}
+
+func newRouteGroup(router *Router, prefix string, ms []Mw) *RouteGroup {
+	return &RouteGroup{router: router, prefix: prefix, ms: ms}
+}
+
+// prefix of the group, including prefixes of its parents
+func (g *RouteGroup) Prefix() string {
+	return g.prefix
+}
+
+// middleware of the group, including middleware of its parents
+func (g *RouteGroup) Middleware() []Mw {
+	return g.ms
+}
+
+// create a sub group. when grp is not nil, it is called with a router whose
+// registrations go to the sub group
+func (g *RouteGroup) Group(prefix string, ms []Mw, grp GroupCall) *RouteGroup {
+	sub := newRouteGroup(g.router, g.prefix+prefix, mergeMiddleware(g.ms, ms))
+	if len(ms) > 0 {
+		g.router.groups = append(g.router.groups, sub)
+	}
+	if grp != nil {
+		g.router.within(sub, grp)
+	}
+	return sub
+}
+
+// handle a request in the group, panic when the route is invalid or conflict with a registered one
+func (g *RouteGroup) Handle(method string, path string, h HttpHandler) *Route {
+	route, err := g.HandleE(method, path, h)
+	if err != nil {
+		panic(err)
+	}
+	return route
+}
+
+// handle a request in the group, return error when the route is invalid or conflict with a registered one
+func (g *RouteGroup) HandleE(method string, path string, h HttpHandler) (*Route, error) {
+	return g.router.register(method, g.prefix+path, g.ms, h)
+}
+
+// on get uri
+func (g *RouteGroup) OnGet(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodGet, path, h)
+}
+
+// on post uri
+func (g *RouteGroup) OnPost(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodPost, path, h)
+}
+
+// on put uri
+func (g *RouteGroup) OnPut(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodPut, path, h)
+}
+
+// on delete uri
+func (g *RouteGroup) OnDelete(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodDelete, path, h)
+}
+
+// on patch uri
+func (g *RouteGroup) OnPatch(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodPatch, path, h)
+}
+
+// on connect uri
+func (g *RouteGroup) OnConnect(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodConnect, path, h)
+}
+
+// on option uri
+func (g *RouteGroup) OnOption(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodOptions, path, h)
+}
+
+// on trace uri
+func (g *RouteGroup) OnTrace(path string, h HttpHandler) *Route {
+	return g.Handle(http.MethodTrace, path, h)
+}
@@ -0,0 +1,154 @@
+package httprouter
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// catchall param of mounted handlers, it's the path with the mount prefix stripped
+const mountParam = "mount_path"
+
+// methods routed to mounted handlers
+var mountMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace,
+}
+
+// http.ResponseWriter writing through w, the underlying writer of Response.
+// headers are shared with Response, and once the status is written Response
+// is marked written so Flush won't write it again
+type responseWriter struct {
+	r *Response
+	w http.ResponseWriter
+	// bytes written through w
+	n int64
+}
+
+func newResponseWriter(r *Response) *responseWriter {
+	return &responseWriter{r, r.writer, 0}
+}
+
+func (w *responseWriter) Header() http.Header {
+	return w.r.headers
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.r.written {
+		return
+	}
+	w.r.written = true
+	w.r.statusCode = statusCode
+	for key, vals := range w.r.headers {
+		w.w.Header()[key] = append([]string(nil), vals...)
+	}
+	w.w.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.r.written {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.w.Write(b)
+	w.n += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.r.written {
+		w.WriteHeader(w.r.statusCode)
+	}
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// take over the connection like websocket upgrade, the response is marked
+// written so nothing is flushed to the hijacked connection
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: hijack", http.ErrNotSupported)
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.r.written = true
+	}
+	return conn, rw, err
+}
+
+// the underlying writer, for http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.w
+}
+
+// convert http.Handler to HttpHandler. h writes through to the client, so
+// compression and ranges of Response are not applied to what h writes. Bag
+// and route params are read in h by BagFromContext and ParamsFromContext
+func WrapHandler(h http.Handler) HttpHandler {
+	return func(r *Response, req *Request) {
+		rw := newResponseWriter(r)
+		h.ServeHTTP(rw, req.Request)
+		r.bytes += rw.n
+	}
+}
+
+// convert http.HandlerFunc to HttpHandler, see WrapHandler
+func WrapHandlerFunc(h http.HandlerFunc) HttpHandler {
+	return WrapHandler(h)
+}
+
+// convert net/http middleware to MiddlewareFunc, so it can be used in Group.
+// the request passed to next by mw, with values it adds into the context, is
+// used by the rest of the chain. when mw replaces the response writer, the
+// response is flushed to that writer once next return
+func WrapMiddleware(mw func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next HttpHandler) HttpHandler {
+		return func(r *Response, req *Request) {
+			rw := newResponseWriter(r)
+			inner := http.HandlerFunc(func(w http.ResponseWriter, hreq *http.Request) {
+				req.Request = hreq
+				if w, ok := w.(*responseWriter); ok && w == rw {
+					next(r, req)
+					return
+				}
+				writer := r.writer
+				r.writer = w
+				defer func() {
+					r.writer = writer
+				}()
+				next(r, req)
+				r.flush(hreq)
+				r.written = true
+			})
+			bytes := r.bytes
+			mw(inner).ServeHTTP(rw, req.Request)
+			// bytes reaching the client, mw may change the size of what flushed to it
+			r.bytes = bytes + rw.n
+		}
+	}
+}
+
+// route requests under prefix to h with the prefix stripped from the path.
+// middleware of the group are applied
+func (g *RouteGroup) Mount(prefix string, h http.Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	handler := func(r *Response, req *Request) {
+		hreq := req.Request.Clone(req.Context())
+		hreq.URL.Path = "/" + req.Param(mountParam)
+		hreq.URL.RawPath = ""
+		rw := newResponseWriter(r)
+		h.ServeHTTP(rw, hreq)
+		r.bytes += rw.n
+	}
+	exact := prefix
+	if exact == "" {
+		exact = "/"
+	}
+	for _, method := range mountMethods {
+		g.Handle(method, exact, handler)
+		g.Handle(method, prefix+"/*"+mountParam, handler)
+	}
+}
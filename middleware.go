@@ -1,10 +1,51 @@
 package httprouter
 
+// middleware called before and after the handler, the handler and the rest of
+// middleware are skipped when Before return false. the result of After is ignored
 type Mw interface {
 	Before(m *Response, req *Request) bool
 	After(m *Response, req *Request) bool
 }
 
+// middleware wrapping the next handler, it can run code around next, skip it,
+// or change the response after it. it's a Mw so both styles can be mixed in a
+// group, and middleware earlier in the list wrap the later ones
+//
+//	timing := MiddlewareFunc(func(next HttpHandler) HttpHandler {
+//		return func(w *Response, req *Request) {
+//			start := time.Now()
+//			next(w, req)
+//			w.WithHeader("X-Elapsed", time.Since(start).String())
+//		}
+//	})
+//	router.Group("/api", []Mw{auth, timing}, nil)
+type MiddlewareFunc func(next HttpHandler) HttpHandler
+
+// MiddlewareFunc only works when it's applied by the router, Before and After do nothing
+func (f MiddlewareFunc) Before(_ *Response, _ *Request) bool {
+	return true
+}
+
+func (f MiddlewareFunc) After(_ *Response, _ *Request) bool {
+	return true
+}
+
+// adapt mid to MiddlewareFunc, Before runs before next and After after it
+func WrapMw(mid Mw) MiddlewareFunc {
+	if f, ok := mid.(MiddlewareFunc); ok {
+		return f
+	}
+	return func(next HttpHandler) HttpHandler {
+		return func(r *Response, req *Request) {
+			if !mid.Before(r, req) {
+				return
+			}
+			defer mid.After(r, req)
+			next(r, req)
+		}
+	}
+}
+
 func mergeMiddleware(m1, m2 []Mw) []Mw {
 	result := []Mw{}
 	for _, mid := range m1 {
@@ -16,3 +57,11 @@ func mergeMiddleware(m1, m2 []Mw) []Mw {
 
 	return result
 }
+
+// wrap h by middleware ms, the first one is the outermost
+func chain(ms []Mw, h HttpHandler) HttpHandler {
+	for i := len(ms) - 1; i >= 0; i-- {
+		h = WrapMw(ms[i])(h)
+	}
+	return h
+}
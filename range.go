@@ -0,0 +1,153 @@
+package httprouter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// Range header is malformed or none of its ranges overlap the body
+var errUnsatisfiableRange = errors.New("unsatisfiable range")
+
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (ra byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", ra.start, ra.start+ra.length-1, size)
+}
+
+// the request ask part of body, and If-Range if any still match the validators of response
+func (r *Response) rangeable(req *http.Request) bool {
+	if r.size < 0 || r.statusCode != http.StatusOK || req.Method != http.MethodGet {
+		return false
+	}
+	if req.Header.Get("Range") == "" {
+		return false
+	}
+	ir := req.Header.Get("If-Range")
+	if ir == "" {
+		return true
+	}
+	// If-Range with entity tag need strong comparison
+	if strings.HasPrefix(ir, `"`) {
+		return ir == r.writer.Header().Get("ETag")
+	}
+	lm := r.writer.Header().Get("Last-Modified")
+	t1, err1 := http.ParseTime(ir)
+	t2, err2 := http.ParseTime(lm)
+	return err1 == nil && err2 == nil && t1.Equal(t2)
+}
+
+// write ranges of body with 206, a single range as is and many ranges as multipart/byteranges
+func (r *Response) flushRanges(ranges []byteRange) error {
+	w := r.writer
+	body := r.body.(io.ReadSeeker)
+	if len(ranges) == 1 {
+		ra := ranges[0]
+		w.Header().Set("Content-Range", ra.contentRange(r.size))
+		w.Header().Set("Content-Length", strconv.FormatInt(ra.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if _, err := body.Seek(ra.start, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.CopyN(w, body, ra.length)
+		return err
+	}
+	mw := multipart.NewWriter(w)
+	ct := w.Header().Get("Content-Type")
+	w.Header().Set("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	w.Header().Del("Content-Length")
+	w.WriteHeader(http.StatusPartialContent)
+	for _, ra := range ranges {
+		header := textproto.MIMEHeader{"Content-Range": {ra.contentRange(r.size)}}
+		if ct != "" {
+			header.Set("Content-Type", ct)
+		}
+		part, err := mw.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if _, err := body.Seek(ra.start, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(part, body, ra.length); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// parse Range header like "bytes=0-499, -500, 1000-" against body size.
+// ranges not overlapping the body are dropped, and error is returned when the
+// header is malformed or no range left. no ranges and no error mean the whole
+// body should be sent, it happens when the ranges ask more than the body itself
+func parseRange(header string, size int64) ([]byteRange, error) {
+	const unit = "bytes="
+	if !strings.HasPrefix(header, unit) {
+		return nil, errUnsatisfiableRange
+	}
+	ranges := []byteRange{}
+	var total int64
+	for _, spec := range strings.Split(header[len(unit):], ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		i := strings.Index(spec, "-")
+		if i < 0 {
+			return nil, errUnsatisfiableRange
+		}
+		first, last := strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+		var ra byteRange
+		if first == "" {
+			// suffix range, the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errUnsatisfiableRange
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			if n > size {
+				n = size
+			}
+			ra = byteRange{size - n, n}
+		} else {
+			start, err := strconv.ParseInt(first, 10, 64)
+			if err != nil || start < 0 {
+				return nil, errUnsatisfiableRange
+			}
+			end := size - 1
+			if last != "" {
+				end, err = strconv.ParseInt(last, 10, 64)
+				if err != nil || end < start {
+					return nil, errUnsatisfiableRange
+				}
+			}
+			if start >= size {
+				continue
+			}
+			if end >= size {
+				end = size - 1
+			}
+			ra = byteRange{start, end - start + 1}
+		}
+		ranges = append(ranges, ra)
+		total += ra.length
+	}
+	if len(ranges) == 0 {
+		return nil, errUnsatisfiableRange
+	}
+	// overlapping ranges asking more than the body, send the whole body instead
+	if total > size {
+		return []byteRange{}, nil
+	}
+	return ranges, nil
+}
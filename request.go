@@ -1,14 +1,19 @@
 package httprouter
 
 import (
+	"context"
 	"errors"
 	"net/http"
-	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+// untyped values of a request, kept for compatibility. it's carried by the
+// request context, see BagFromContext. use Request.Param for route params and
+// ContextKey for request scoped values
 type Bagt struct {
+	mu   sync.RWMutex
 	body map[string]interface{}
 }
 
@@ -19,21 +24,32 @@ func NewBagt() *Bagt {
 }
 
 func (p *Bagt) Set(k string, v interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
 	p.body[k] = v
 }
 
 func (p *Bagt) Get(k string) interface{} {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	return p.body[k]
 }
 
 func (p *Bagt) Del(k string) {
-	if p.Exist(k) {
-		delete(p.body, k)
-	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.body, k)
 }
 
+// iterate a snapshot of the bag, stop when handle return false
 func (p *Bagt) Each(handle func(k string, v interface{}) bool) bool {
+	p.mu.RLock()
+	snapshot := make(map[string]interface{}, len(p.body))
 	for k, v := range p.body {
+		snapshot[k] = v
+	}
+	p.mu.RUnlock()
+	for k, v := range snapshot {
 		if !handle(k, v) {
 			return false
 		}
@@ -42,14 +58,108 @@ func (p *Bagt) Each(handle func(k string, v interface{}) bool) bool {
 }
 
 func (p *Bagt) Exist(k string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	_, ok := p.body[k]
 	return ok
 }
 
+type bagKey struct{}
+
+type paramsKey struct{}
+
+// bag of the request context, nil when the request is not from router
+func BagFromContext(ctx context.Context) *Bagt {
+	bag, _ := ctx.Value(bagKey{}).(*Bagt)
+	return bag
+}
+
+// route params of the request context, nil when no route matched
+func ParamsFromContext(ctx context.Context) map[string]string {
+	params, _ := ctx.Value(paramsKey{}).(map[string]string)
+	return params
+}
+
 // wrap http.Request, and provide some useful functions
 type Request struct {
 	Bag *Bagt
 	*http.Request
+	params map[string]string
+	// validator of router, used by Validate
+	validator *Validator
+	// form values parsed by bracket keys, see FormNested
+	nested map[string]interface{}
+	// matched route, nil for files and not found
+	route *Route
+}
+
+func newRequest(req *http.Request) *Request {
+	bag := NewBagt()
+	ctx := context.WithValue(req.Context(), bagKey{}, bag)
+	return &Request{Bag: bag, Request: req.WithContext(ctx)}
+}
+
+// set route params, they are also set into Bag for compatibility
+func (req *Request) setParams(keys, values []string) {
+	params := make(map[string]string, len(keys))
+	for i, k := range keys {
+		params[k] = values[i]
+		req.Bag.Set(k, values[i])
+	}
+	req.params = params
+	req.WithValue(paramsKey{}, params)
+}
+
+// set a request scoped value into the request context, middleware and handler
+// executed later see it through req.Context()
+func (req *Request) WithValue(key, val interface{}) {
+	req.Request = req.Request.WithContext(context.WithValue(req.Context(), key, val))
+}
+
+// route param, empty when not exist
+func (req *Request) Param(name string) string {
+	return req.params[name]
+}
+
+// route matched by the request, nil when it's served by files or NotFound
+func (req *Request) Route() *Route {
+	return req.route
+}
+
+// all route params
+func (req *Request) Params() map[string]string {
+	params := make(map[string]string, len(req.params))
+	for k, v := range req.params {
+		params[k] = v
+	}
+	return params
+}
+
+// read route param as int64, if you need other int type, use type convert
+func (req *Request) ParamInt(name string) (int64, error) {
+	val, ok := req.params[name]
+	if !ok {
+		return 0, errors.New("param not found")
+	}
+	return strconv.ParseInt(val, 10, 64)
+}
+
+// read route param as uint64, if you need other uint type, use type convert
+func (req *Request) ParamUint(name string) (uint64, error) {
+	val, ok := req.params[name]
+	if !ok {
+		return 0, errors.New("param not found")
+	}
+	return strconv.ParseUint(val, 10, 64)
+}
+
+// read route param as float64, if you need other float type, use type convert
+func (req *Request) ParamFloat(name string) (float64, error) {
+	val, ok := req.params[name]
+	if !ok {
+		return 0.0, errors.New("param not found")
+	}
+	return strconv.ParseFloat(val, 64)
 }
 
 // read form field as int64, if you need other int type, use type convert
@@ -89,18 +199,11 @@ func (req *Request) FormBool(fieldName string) bool {
 	return !(val == "" || val == "0" || val == "false")
 }
 
-// read form field as string slice, key[\d+] will be recognised item
+// read form field as string slice, comma separated values of key and items of
+// key[] or key[\d+] are recognised
 func (req *Request) FormSlice(fieldname string) []string {
 	r := strings.Split(req.FormValue(fieldname), ",")
-	req.ParseForm()
-	for key, val := range req.Form {
-		var matched bool
-		matched, _ = regexp.MatchString("^"+fieldname+"\\[\\d*\\]$", key)
-		if !matched {
-			continue
-		}
-		r = append(r, val...)
-	}
+	r = append(r, nestedStrings(req.FormNested(fieldname))...)
 	res := []string{}
 	for _, val := range r {
 		if val != "" {
@@ -111,19 +214,13 @@ func (req *Request) FormSlice(fieldname string) []string {
 	return res
 }
 
-// read form field as string slice, key[\w+] will be recognised item
+// read form field as string map, key[name] will be recognised item
 func (req *Request) FormMap(fieldname string) map[string]string {
 	result := make(map[string]string)
-	var field string
-	var reg *regexp.Regexp
-	var keyReg *regexp.Regexp
-	reg, _ = regexp.Compile("^" + fieldname + "\\[\\w+\\]$")
-	keyReg, _ = regexp.Compile("\\[\\w+\\]")
-	req.ParseForm()
-	for key, val := range req.Form {
-		field = keyReg.FindString(reg.FindString(key))
-		if field != "" {
-			result[field[1:len(field)-1]] = val[0]
+	m, _ := req.FormNested(fieldname).(map[string]interface{})
+	for key, val := range m {
+		if s, ok := val.(string); ok {
+			result[key] = s
 		}
 	}
 
@@ -0,0 +1,86 @@
+package httprouter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+var requestIDKey = NewContextKey[string]("request id")
+
+// config of RequestID, empty fields take the defaults
+type RequestIDConfig struct {
+	// header carrying the id in request and response, X-Request-ID by default
+	Header string
+	// generate an id when the request has no valid one, 16 random bytes in hex by default
+	Generate func() string
+	// accept the id from request or not, by default an id of 1 to 128 letters,
+	// digits and -_.:+/= is accepted
+	Valid func(id string) bool
+}
+
+func generateRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// middleware taking the request id from the request header, or generating one
+// when it's missing or invalid. the id is stored in the request context and
+// echoed in the response header, AccessLogger and the default PanicHandler log
+// it. conf can be nil
+//
+//	router.Use(RequestID(nil), AccessLogger(SlogSink(slog.Default())))
+func RequestID(conf *RequestIDConfig) MiddlewareFunc {
+	c := RequestIDConfig{Header: "X-Request-ID", Generate: generateRequestID, Valid: validRequestID}
+	if conf != nil {
+		if conf.Header != "" {
+			c.Header = conf.Header
+		}
+		if conf.Generate != nil {
+			c.Generate = conf.Generate
+		}
+		if conf.Valid != nil {
+			c.Valid = conf.Valid
+		}
+	}
+	return func(next HttpHandler) HttpHandler {
+		return func(r *Response, req *Request) {
+			id := req.Header.Get(c.Header)
+			if !c.Valid(id) {
+				id = c.Generate()
+			}
+			requestIDKey.Set(req, id)
+			r.WithHeader(c.Header, id)
+			next(r, req)
+		}
+	}
+}
+
+// request id set by RequestID, empty when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := requestIDKey.From(ctx)
+	return id
+}
+
+// request id set by RequestID, empty when there is none
+func (req *Request) RequestID() string {
+	return RequestIDFromContext(req.Context())
+}
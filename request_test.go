@@ -0,0 +1,354 @@
+package httprouter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	. "testing"
+)
+
+var userKey = NewContextKey[string]("user")
+
+// middleware sets a typed value for test
+type authMw struct{}
+
+func (mid *authMw) Before(_ *Response, req *Request) bool {
+	userKey.Set(req, "young")
+	req.Bag.Set("legacy", true)
+	return true
+}
+
+func (mid *authMw) After(_ *Response, _ *Request) bool {
+	return true
+}
+
+func TestRequestScope(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	var (
+		id       int64
+		idErr    error
+		name     string
+		missErr  error
+		user     string
+		bagParam interface{}
+		ctxParam string
+		legacy   interface{}
+	)
+	r.Group("", []Mw{&authMw{}}, func(router *Router) {
+		router.OnGet("/users/:id/articles/:name", func(w *Response, req *Request) {
+			id, idErr = req.ParamInt("id")
+			name = req.Param("name")
+			_, missErr = req.ParamInt("page")
+			user, _ = userKey.Get(req)
+			bagParam = req.Bag.Get("id")
+			ctxParam = ParamsFromContext(req.Context())["name"]
+			legacy = BagFromContext(req.Context()).Get("legacy")
+		})
+	})
+	r.ServeHTTP(getWriter(), getRequest("GET", "/users/42/articles/hello"))
+	if id != 42 || idErr != nil || name != "hello" || missErr == nil {
+		t.Errorf("params: got %d, %v, %q, %v", id, idErr, name, missErr)
+	}
+	if user != "young" {
+		t.Errorf("context key: got %q", user)
+	}
+	if bagParam != "42" || ctxParam != "hello" || legacy != true {
+		t.Errorf("compatibility: got %v, %q, %v", bagParam, ctxParam, legacy)
+	}
+}
+
+type bindPaging struct {
+	Page     int `query:"page"`
+	PageSize int `query:"page_size"`
+}
+
+type bindArticle struct {
+	bindPaging
+	UserID   uint64                `param:"user_id"`
+	Title    string                `form:"title" json:"title"`
+	Tags     []string              `form:"tags" json:"tags"`
+	Draft    *bool                 `form:"draft" json:"draft"`
+	Q        string                `query:"q"`
+	Cover    *multipart.FileHeader `form:"cover"`
+	Ignored  string                `form:"-"`
+	internal string
+}
+
+func TestBind(t *T) {
+	r := NewRouter()
+	var (
+		dst        bindArticle
+		err        error
+		parseFirst bool
+	)
+	r.OnPost("/users/:user_id/articles", func(w *Response, req *Request) {
+		dst = bindArticle{}
+		if parseFirst {
+			req.ParseForm()
+		}
+		err = req.Bind(&dst)
+	})
+	post := func(contentType, body string) {
+		req := httptest.NewRequest("POST", "/users/7/articles?q=go&page=2&page_size=20", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	post("application/json", `{"title":"hello","tags":["a","b"],"draft":true}`)
+	if err != nil || dst.UserID != 7 || dst.Title != "hello" || len(dst.Tags) != 2 || dst.Draft == nil || !*dst.Draft || dst.Q != "go" || dst.Page != 2 || dst.PageSize != 20 {
+		t.Errorf("json: got %+v, %v", dst, err)
+	}
+
+	post("application/x-www-form-urlencoded", "title=hi&tags=x&tags=y&draft=on&Ignored=1")
+	if err != nil || dst.Title != "hi" || strings.Join(dst.Tags, ",") != "x,y" || !*dst.Draft || dst.Ignored != "" {
+		t.Errorf("form: got %+v, %v", dst, err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("title", "multi")
+	fw, _ := mw.CreateFormFile("cover", "cover.png")
+	fw.Write([]byte("png"))
+	mw.Close()
+	post(mw.FormDataContentType(), buf.String())
+	if err != nil || dst.Title != "multi" || dst.Cover == nil || dst.Cover.Filename != "cover.png" {
+		t.Errorf("multipart: got %+v, %v", dst, err)
+	}
+	parseFirst = true
+	post(mw.FormDataContentType(), buf.String())
+	parseFirst = false
+	if err != nil || dst.Title != "multi" || dst.Cover == nil {
+		t.Errorf("multipart after ParseForm: got %+v, %v", dst, err)
+	}
+
+	var fes FieldErrors
+	post("application/x-www-form-urlencoded", "title=%zz")
+	if err == nil || errors.As(err, &fes) {
+		t.Errorf("malformed urlencoded body: got %v", err)
+	}
+	post("multipart/form-data", "title=x")
+	if err == nil || errors.As(err, &fes) {
+		t.Errorf("multipart body without boundary: got %v", err)
+	}
+
+	post("application/json", `{"title":1}`)
+	var errs FieldErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Source != "json" || errs[0].Key != "title" {
+		t.Errorf("json field error: got %v", err)
+	}
+
+	post("application/x-www-form-urlencoded", "draft=maybe")
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "Draft" || errs[0].Source != "form" {
+		t.Errorf("form field error: got %v", err)
+	}
+
+	post("text/csv", "a,b")
+	if !errors.Is(err, ErrUnsupportedContentType) {
+		t.Errorf("unsupported content type: got %v", err)
+	}
+}
+
+type validSignup struct {
+	Email    string `json:"email" validate:"required,email"`
+	Name     string `json:"name" validate:"required,min=2,max=8"`
+	Age      *int   `json:"age" validate:"min=18"`
+	Sort     string `query:"sort" validate:"oneof=asc desc"`
+	Homepage string `json:"homepage" validate:"url"`
+	Invite   string `json:"invite" validate:"invite"`
+	Address  struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+}
+
+func TestValidate(t *T) {
+	r := NewRouter()
+	r.Validator.Register("invite", func(value interface{}, _ string) error {
+		if value.(string) != "friend" {
+			return errors.New("is not a valid invite code")
+		}
+		return nil
+	})
+	r.OnPost("/signup", func(w *Response, req *Request) {
+		var dst validSignup
+		if err := req.BindValid(&dst); err != nil {
+			panic(err)
+		}
+		w.WithString("welcome " + dst.Name)
+	})
+	post := func(query, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/signup"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("?sort=asc", `{"email":"a@b.com","name":"bob","age":20,"invite":"friend","address":{"city":"x"}}`)
+	if w.Code != 200 || w.Body.String() != "welcome bob" {
+		t.Errorf("valid: got %d %s", w.Code, w.Body.String())
+	}
+
+	w = post("?sort=up", `{"email":"a b","name":"b","age":3,"homepage":"/x","invite":"foe"}`)
+	var resp struct {
+		Message string
+		Errors  []struct{ Field, Rule, Message string }
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != 422 || w.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("invalid: got %d %s", w.Code, w.Body.String())
+	}
+	got := []string{}
+	for _, e := range resp.Errors {
+		got = append(got, e.Field+":"+e.Rule)
+	}
+	want := "email:email,name:min,age:min,sort:oneof,homepage:url,invite:invite,city:required"
+	if strings.Join(got, ",") != want {
+		t.Errorf("invalid fields: got %v, want %s", got, want)
+	}
+
+	w = post("", `{"name":1}`)
+	if w.Code != 422 || !strings.Contains(w.Body.String(), `"field":"name","rule":"type"`) {
+		t.Errorf("bind error: got %d %s", w.Code, w.Body.String())
+	}
+
+	var bad struct {
+		Name string `validate:"nope"`
+	}
+	if err := NewValidator().Validate(&bad); err == nil || isUnprocessable(err) {
+		t.Errorf("unknown rule: got %v", err)
+	}
+}
+
+func TestFormNested(t *T) {
+	form := "filter[status][]=open&filter[status][]=closed&filter[user][name]=bob" +
+		"&items[][id]=1&items[][id]=2&tags=a,b&tags[1]=d&tags[0]=c" +
+		"&a.b*[k]=v&bad[x=1&[y]=2&deep[a][b][c]=z"
+	req := httptest.NewRequest("POST", "/?q=go", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	wreq := newRequest(req)
+
+	want := map[string]interface{}{
+		"status": []interface{}{"open", "closed"},
+		"user":   map[string]interface{}{"name": "bob"},
+	}
+	if got := wreq.FormNested("filter"); !reflect.DeepEqual(got, want) {
+		t.Errorf("filter: got %#v", got)
+	}
+	want = map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{"c": "z"}}}
+	if got := wreq.FormNested("deep"); !reflect.DeepEqual(got, want) {
+		t.Errorf("deep: got %#v", got)
+	}
+	if got := wreq.FormNested("items"); len(got.([]interface{})) != 2 {
+		t.Errorf("items: got %#v", got)
+	}
+	if got := wreq.FormNested("q"); got != "go" {
+		t.Errorf("plain field: got %#v", got)
+	}
+	if got := wreq.FormNested("bad[x"); got != "1" {
+		t.Errorf("malformed key: got %#v", got)
+	}
+	if got := wreq.FormNested("[y]"); got != "2" {
+		t.Errorf("key without name: got %#v", got)
+	}
+	if got := strings.Join(wreq.FormSlice("tags"), ","); got != "a,b,c,d" {
+		t.Errorf("FormSlice: got %s", got)
+	}
+	if got := wreq.FormMap("a.b*"); got["k"] != "v" || len(got) != 1 {
+		t.Errorf("FormMap with metacharacters: got %v", got)
+	}
+	if got := wreq.FormMap("filter"); len(got) != 0 {
+		t.Errorf("FormMap skips nested values: got %v", got)
+	}
+}
+
+func TestRequestID(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	var logged, panicked string
+	r.Use(AccessLogger(func(l *AccessLog) {
+		logged = l.RequestID
+	}), RequestID(nil))
+	r.PanicHandler = func(w *Response, req *Request, _ interface{}) {
+		panicked = req.RequestID()
+		w.WithStatus(http.StatusInternalServerError)
+	}
+	r.OnGet("/id", WrapHandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte(RequestIDFromContext(req.Context())))
+	}))
+	r.OnGet("/panic", func(w *Response, req *Request) {
+		panic("boom")
+	})
+	w := serveRequest(r, "GET", "/id", http.Header{"X-Request-ID": {"req-1.a:b"}})
+	if w.Body.String() != "req-1.a:b" || w.Header().Get("X-Request-ID") != "req-1.a:b" || logged != "req-1.a:b" {
+		t.Errorf("incoming id: got %q, header %q, logged %q", w.Body.String(), w.Header().Get("X-Request-ID"), logged)
+	}
+	for _, bad := range []string{"", "has space", "quote\"", strings.Repeat("a", 129)} {
+		w = serveRequest(r, "GET", "/id", http.Header{"X-Request-ID": {bad}})
+		if id := w.Body.String(); len(id) != 32 || id == bad || w.Header().Get("X-Request-ID") != id || logged != id {
+			t.Errorf("invalid id %q: got %q, header %q, logged %q", bad, id, w.Header().Get("X-Request-ID"), logged)
+		}
+	}
+	w = serveRequest(r, "GET", "/panic", http.Header{"X-Request-ID": {"p-1"}})
+	if w.Code != http.StatusInternalServerError || panicked != "p-1" || w.Header().Get("X-Request-ID") != "p-1" || logged != "p-1" {
+		t.Errorf("panic: got %d, panic handler %q, header %q, logged %q", w.Code, panicked, w.Header().Get("X-Request-ID"), logged)
+	}
+
+	r = NewRouter()
+	r.Tries = []int{API}
+	r.Use(RequestID(&RequestIDConfig{Header: "X-Trace-ID", Generate: func() string { return "gen" }}))
+	r.OnGet("/id", func(w *Response, req *Request) {
+		w.WithString(req.RequestID())
+	})
+	req := httptest.NewRequest("GET", "/id", nil)
+	req.Header.Set("X-Request-ID", "ignored")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "gen" || w.Header().Get("X-Trace-ID") != "gen" {
+		t.Errorf("custom config: got %q, header %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestMultipartCleanup(t *T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.OnPost("/upload", func(w *Response, req *Request) {
+		// values set into context replace the request after parsing
+		userKey.Set(req, "alice")
+		if err := req.ParseMultipartForm(1024); err != nil {
+			t.Error(err)
+		}
+		userKey.Set(req, "bob")
+		entries, _ := os.ReadDir(dir)
+		w.WithString(fmt.Sprint(len(entries)))
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, _ := mw.CreateFormFile("file", "big.bin")
+	fw.Write(bytes.Repeat([]byte("x"), 1<<20))
+	mw.Close()
+	res, err := http.Post(srv.URL+"/upload", mw.FormDataContentType(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if string(body) != "1" {
+		t.Fatalf("upload should be stored in a temp file, got %q files", body)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("temp files left after response: %d", len(entries))
+	}
+}
@@ -2,8 +2,9 @@ package httprouter
 
 import (
 	"bytes"
-	"compress/gzip"
+	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 	"strings"
@@ -12,14 +13,25 @@ import (
 // wrap http.ResponseWriter, provide some useful functions
 type Response struct {
 	statusCode int
-	headers    map[string]string
+	headers    http.Header
 	body       io.Reader
 	writer     http.ResponseWriter
+	compressor *Compressor
+	// size of body when it supports range requests, otherwise -1
+	size         int64
+	contentTypes *ContentTypes
+	// status and body are written through by a net/http handler, see WrapHandler
+	written bool
+	// bytes of body written to client
+	bytes   int64
+	onFlush []func()
+	// headers set by router-wide middleware, kept by reset
+	kept http.Header
 }
 
 // new response writer
 func NewResponse(w http.ResponseWriter) *Response {
-	return &Response{200, make(map[string]string), nil, w}
+	return &Response{200, make(http.Header), nil, w, defaultCompressor, -1, defaultContentTypes, false, 0, nil, nil}
 }
 
 func (r *Response) StatusCode() int {
@@ -31,7 +43,7 @@ func (r *Response) Writer() http.ResponseWriter {
 }
 
 // get response headers you setted
-func (r *Response) Headers() map[string]string {
+func (r *Response) Headers() http.Header {
 	return r.headers
 }
 
@@ -48,32 +60,144 @@ func (r *Response) WithStatus(statusCode int) *Response {
 
 // add http header to respond, if same key assign value many times, last time will be effective
 func (r *Response) WithHeader(key, val string) *Response {
-	r.headers[key] = val
+	r.headers.Set(key, val)
+	return r
+}
+
+// add http header to respond, values of the same key are all sent
+func (r *Response) AddHeader(key, val string) *Response {
+	r.headers.Add(key, val)
+	return r
+}
+
+// remove all values of the header key
+func (r *Response) DelHeader(key string) *Response {
+	r.headers.Del(key)
+	return r
+}
+
+// add a Set-Cookie header, invalid cookie is dropped
+func (r *Response) SetCookie(cookie *http.Cookie) *Response {
+	if v := cookie.String(); v != "" {
+		r.headers.Add("Set-Cookie", v)
+	}
+	return r
+}
+
+// set compressor used when flushing, nil to disable compression
+func (r *Response) WithCompressor(c *Compressor) *Response {
+	r.compressor = c
+	return r
+}
+
+// set content types used to resolve Content-Type of files
+func (r *Response) WithContentTypes(c *ContentTypes) *Response {
+	r.contentTypes = c
 	return r
 }
 
 // read content to set as body from io.Reader
 func (r *Response) WithBody(body io.Reader) {
 	r.body = body
+	r.size = -1
 }
 
 func (r *Response) WithString(content string) {
 	r.WithHeader("Content-Type", "text/plain")
 	r.body = strings.NewReader(content)
+	r.size = -1
 }
 
+// read file p as body, range requests are supported
 func (r *Response) WithFile(p string) error {
 	body, err := os.Open(p)
 	if err != nil {
 		return err
 	}
-	r.body = body
-	ct := guessContentType(p)
+	r.withFile(body, p)
+
+	return nil
+}
+
+// read file name of fsys as body, range requests are supported when the file is seekable
+func (r *Response) WithFSFile(fsys fs.FS, name string) error {
+	body, err := fsys.Open(name)
+	if err != nil {
+		return err
+	}
+	r.withFile(body, name)
+
+	return nil
+}
+
+func (r *Response) withFile(f fs.File, name string) {
+	r.size = -1
+	ct := r.contentTypes.ByName(name)
+	var replay []byte
+	if ct == "" {
+		ct, replay = sniff(f)
+	}
 	if ct != "" {
 		r.WithHeader("Content-Type", ct)
 	}
+	if replay != nil {
+		// sniffed bytes can't be seeked back, replay them and keep closing the file
+		r.body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(replay), f), f}
+		return
+	}
+	r.body = f
+	if _, ok := f.(io.Seeker); !ok {
+		return
+	}
+	if stat, err := f.Stat(); err == nil && stat.Mode().IsRegular() {
+		r.size = stat.Size()
+		r.WithHeader("Accept-Ranges", "bytes")
+	}
+}
 
-	return nil
+// bytes of body written to client after Flush, it's the compressed size when compressed
+func (r *Response) BytesWritten() int64 {
+	return r.bytes
+}
+
+// call fn after the response is flushed, like logging the final status and size
+func (r *Response) OnFlush(fn func()) {
+	r.onFlush = append(r.onFlush, fn)
+}
+
+// count bytes written to the underlying writer, and record the status
+// actually written like 206 and 416 of range requests
+type countWriter struct {
+	http.ResponseWriter
+	r *Response
+}
+
+func (w *countWriter) WriteHeader(statusCode int) {
+	w.r.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *countWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.r.bytes += int64(n)
+	return n, err
+}
+
+// drop the body and the headers set after router-wide middleware, the body is
+// closed if it's a closer
+func (r *Response) reset() {
+	if c, ok := r.body.(io.Closer); ok {
+		c.Close()
+	}
+	r.body = nil
+	r.size = -1
+	r.headers = r.kept.Clone()
+	if r.headers == nil {
+		r.headers = make(http.Header)
+	}
 }
 
 // output a server error
@@ -83,30 +207,61 @@ func (r *Response) InternalError(err error) {
 
 // output result
 func (r *Response) Flush(req *http.Request) error {
+	defer func() {
+		for _, fn := range r.onFlush {
+			fn()
+		}
+	}()
+	return r.flush(req)
+}
+
+func (r *Response) flush(req *http.Request) error {
+	if r.written {
+		if c, ok := r.body.(io.Closer); ok {
+			c.Close()
+		}
+		return nil
+	}
+	writer := r.writer
+	r.writer = &countWriter{writer, r}
+	defer func() {
+		r.writer = writer
+	}()
 	w := r.writer
-	for key, val := range r.headers {
-		w.Header().Set(key, val)
+	for key, vals := range r.headers {
+		w.Header()[key] = append([]string(nil), vals...)
+	}
+	if c, ok := r.body.(io.Closer); ok {
+		defer c.Close()
+	}
+	if r.rangeable(req) {
+		ranges, err := parseRange(req.Header.Get("Range"), r.size)
+		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", r.size))
+			w.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+			return nil
+		}
+		if len(ranges) > 0 {
+			return r.flushRanges(ranges)
+		}
+	}
+	compress := r.body != nil && r.compressor != nil && r.compressor.compressible(w.Header())
+	if compress {
+		addVary(w.Header(), "Accept-Encoding")
 	}
 	if r.body == nil {
 		w.WriteHeader(r.statusCode)
 		return nil
 	}
-	ae := []byte(req.Header.Get("Accept-Encoding"))
-	if bytes.Index(ae, []byte("gzip")) != -1 {
-		w.WriteHeader(r.statusCode)
-		io.Copy(w, r.body)
-		return nil
+	if compress {
+		return r.compressor.write(w, req, r.statusCode, r.body)
 	}
-
-	w.Header().Set("Content-Encoding", "gzip")
 	w.WriteHeader(r.statusCode)
+	// response to HEAD has the same headers as GET but no body
+	if req.Method == http.MethodHead {
+		return nil
+	}
+	_, err := io.Copy(w, r.body)
 
-	var buf bytes.Buffer
-	z := gzip.NewWriter(&buf)
-	defer z.Close()
-	io.Copy(z, r.body)
-	z.Flush()
-	io.Copy(w, &buf)
-
-	return nil
+	return err
 }
@@ -0,0 +1,112 @@
+package httprouter
+
+import (
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	. "testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestResponseHeaders(t *T) {
+	rec := httptest.NewRecorder()
+	r := NewResponse(rec)
+	r.WithHeader("X-Single", "a").WithHeader("X-Single", "b")
+	r.AddHeader("Link", "</a.css>; rel=preload").AddHeader("Link", "</b.js>; rel=preload")
+	r.AddHeader("X-Removed", "a").DelHeader("X-Removed")
+	r.SetCookie(&http.Cookie{Name: "session", Value: "1"})
+	r.SetCookie(&http.Cookie{Name: "theme", Value: "dark"})
+	r.Flush(getRequest("GET", "/"))
+
+	h := rec.Header()
+	if v := h["X-Single"]; len(v) != 1 || v[0] != "b" {
+		t.Errorf("with header: got %v", v)
+	}
+	if v := h["Link"]; len(v) != 2 {
+		t.Errorf("add header: got %v", v)
+	}
+	if _, ok := h["X-Removed"]; ok {
+		t.Error("del header fail")
+	}
+	if v := h["Set-Cookie"]; len(v) != 2 || v[0] != "session=1" || v[1] != "theme=dark" {
+		t.Errorf("set cookie: got %v", v)
+	}
+}
+
+func TestRange(t *T) {
+	content := "0123456789abcdefghij"
+	r := NewRouter()
+	r.Tries = []int{API, PATHFILE}
+	r.FS = fstest.MapFS{
+		"video.mp4": {Data: []byte(content), ModTime: time.Date(2020, 9, 17, 8, 0, 0, 0, time.UTC)},
+	}
+	f, err := ioutil.TempFile("", "httprouter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(content)
+	f.Close()
+	r.OnGet("/download", func(w *Response, req *Request) {
+		w.WithFile(f.Name())
+	})
+
+	cases := []struct {
+		rangeHeader  string
+		code         int
+		body         string
+		contentRange string
+	}{
+		{"", 200, content, ""},
+		{"bytes=0-4", 206, "01234", "bytes 0-4/20"},
+		{"bytes=15-", 206, "fghij", "bytes 15-19/20"},
+		{"bytes=-3", 206, "hij", "bytes 17-19/20"},
+		{"bytes=18-100", 206, "ij", "bytes 18-19/20"},
+		{"bytes=30-40", 416, "", "bytes */20"},
+		{"bytes=5-1", 416, "", "bytes */20"},
+		{"lines=1-2", 416, "", "bytes */20"},
+		{"bytes=0-19,0-19", 200, content, ""},
+	}
+	for _, p := range []string{"/video.mp4", "/download"} {
+		for _, c := range cases {
+			rec := serveRequest(r, "GET", p, map[string][]string{"Range": {c.rangeHeader}})
+			if rec.Code != c.code || rec.Body.String() != c.body || rec.Header().Get("Content-Range") != c.contentRange {
+				t.Errorf("%s %q: got %d, %q, %q", p, c.rangeHeader, rec.Code, rec.Body.String(), rec.Header().Get("Content-Range"))
+			}
+			if rec.Header().Get("Accept-Ranges") != "bytes" && rec.Code != 416 {
+				t.Errorf("%s %q: Accept-Ranges not advertised", p, c.rangeHeader)
+			}
+		}
+	}
+
+	rec := serveRequest(r, "GET", "/video.mp4", map[string][]string{"Range": {"bytes=0-1, 10-11"}})
+	_, params, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	mr := multipart.NewReader(rec.Body, params["boundary"])
+	expects := []string{"01", "ab"}
+	for i := 0; ; i++ {
+		part, err := mr.NextPart()
+		if err != nil {
+			if i != len(expects) {
+				t.Errorf("multipart: expect %d parts, got %d", len(expects), i)
+			}
+			break
+		}
+		b, _ := ioutil.ReadAll(part)
+		if i >= len(expects) || string(b) != expects[i] || part.Header.Get("Content-Type") != "video/mp4" {
+			t.Errorf("multipart part %d: got %q, %v", i, b, part.Header)
+		}
+	}
+
+	rec = serveRequest(r, "GET", "/video.mp4", map[string][]string{"Range": {"bytes=0-4"}, "If-Range": {"Thu, 17 Sep 2020 08:00:00 GMT"}})
+	if rec.Code != 206 {
+		t.Errorf("if range match: got %d", rec.Code)
+	}
+	rec = serveRequest(r, "GET", "/video.mp4", map[string][]string{"Range": {"bytes=0-4"}, "If-Range": {"Wed, 16 Sep 2020 08:00:00 GMT"}})
+	if rec.Code != 200 || rec.Body.String() != content {
+		t.Errorf("if range not match: got %d", rec.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package httprouter
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	// route name is already used by another route
+	ErrDuplicateName = errors.New("duplicate route name")
+	// no route registered with the name
+	ErrRouteNotFound = errors.New("route not found")
+	// param needed by the route pattern is not provided
+	ErrMissingParam = errors.New("missing route param")
+)
+
+// a registered route, returned by Handle, OnGet etc.
+type Route struct {
+	router *Router
+	method string
+	path   string
+	keys   []string
+	ms     []Mw
+	call   HttpHandler
+	name   string
+	// call wrapped by ms, composed once on register
+	handler HttpHandler
+	// chains of preflight and MethodNotAllowed, composed on first use
+	options    lazyHandler
+	notAllowed lazyHandler
+}
+
+// handler composed once on first use
+type lazyHandler struct {
+	once sync.Once
+	h    HttpHandler
+}
+
+func (l *lazyHandler) get(compose func() HttpHandler) HttpHandler {
+	l.once.Do(func() {
+		l.h = compose()
+	})
+	return l.h
+}
+
+// http method of the route
+func (route *Route) Method() string {
+	return route.method
+}
+
+// full pattern of the route, including group prefixes
+func (route *Route) Path() string {
+	return route.path
+}
+
+// name the route so it can be used to build url with Router.URL, panic when the
+// name is already used by another route
+func (route *Route) Name(name string) *Route {
+	if exist, ok := route.router.names[name]; ok && exist != route {
+		panic(fmt.Errorf("%w %q: %s %s and %s %s", ErrDuplicateName, name, exist.method, exist.path, route.method, route.path))
+	}
+	if route.name != "" {
+		delete(route.router.names, route.name)
+	}
+	route.name = name
+	route.router.names[name] = route
+	return route
+}
+
+// build url path of the route with params. params are path escaped, a catchall
+// param keeps its '/' as separator
+func (route *Route) URL(params map[string]string) (string, error) {
+	segs := splitPath(route.path)
+	for i, seg := range segs {
+		if !isParam(seg) && !isCatchAll(seg) {
+			continue
+		}
+		val, ok := params[seg[1:]]
+		if !ok {
+			return "", fmt.Errorf("%w %s for %s", ErrMissingParam, seg[1:], route.path)
+		}
+		if isParam(seg) {
+			segs[i] = url.PathEscape(val)
+			continue
+		}
+		parts := strings.Split(val, "/")
+		for j, part := range parts {
+			parts[j] = url.PathEscape(part)
+		}
+		segs[i] = strings.Join(parts, "/")
+	}
+	return strings.Join(segs, "/"), nil
+}
+
+// build url path of the route named name, see Route.URL
+func (router *Router) URL(name string, params map[string]string) (string, error) {
+	route, ok := router.names[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrRouteNotFound, name)
+	}
+	return route.URL(params)
+}
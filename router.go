@@ -2,10 +2,15 @@
 package httprouter
 
 import (
-	restfulpath "github.com/yang-zzhong/go-restfulpath"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
 	"net/http"
-	"os"
-	. "path"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
 )
 
 const (
@@ -17,7 +22,8 @@ const (
 // http handler type
 type HttpHandler func(*Response, *Request)
 
-// router as file server, when output file, execute the callback. here is the type
+// router as file server, when output file, execute the callback with the name of
+// file in the static file system. here is the type
 type onFileHandler func(*Response, *http.Request, string) bool
 
 // group call type
@@ -26,39 +32,129 @@ type GroupCall func(router *Router)
 // when uri match, the callback will be executed. warning that, when different method, uri possibly match many times
 type BeforeExecute func(http.ResponseWriter, *http.Request) bool
 
+// when handler or middleware panic, the callback will be executed with the recovered value
+type PanicHandler func(*Response, *Request, interface{})
+
+// respond an error of the request, like errors of binding or validating fields
+type ErrorHandler func(*Response, *Request, error)
+
 type Router struct {
 	Tries           []int
 	DocRoot         string
 	EntryFile       string
 	BeforePathFile  onFileHandler
 	BeforeEntryFile onFileHandler
-	configs         []config
-	ms              []Mw
-	prefix          string
-}
+	PanicHandler    PanicHandler
+
+	// static files served by PATHFILE and ENTRYFILE like embed.FS, DocRoot is used when it's nil
+	FS fs.FS
+
+	// executed when no route or file match the request, middleware of the group
+	// with the longest prefix matching the path are applied
+	NotFound HttpHandler
+
+	// executed when the path match some routes but none accept the method, the
+	// Allow header is set and middleware of the matched routes are applied
+	MethodNotAllowed HttpHandler
+
+	// compress response body, nil to disable compression
+	Compressor *Compressor
+
+	// serve files and directories starting with '.', .well-known is always served
+	ServeHidden bool
+
+	// symlink policy of static files on DocRoot, SymlinkInRoot by default
+	Symlinks int
+
+	// Cache-Control of static files, the first policy matching the file is used
+	CachePolicies []CachePolicy
+
+	// content types of files, add types to it at runtime
+	ContentTypes *ContentTypes
+
+	// files served when PATHFILE match a directory, in order of preference
+	IndexFiles []string
+
+	// list the directory when PATHFILE match a directory without index file
+	DirListing bool
 
-type config struct {
-	method string
-	path   string
-	ms     []Mw
-	call   HttpHandler
+	// serve precompressed siblings like app.js.br and app.js.gz of static files
+	// when the client accept their encoding, off by default. with it on, a
+	// request to backup.tar may be served backup.tar.gz, and static responses
+	// vary by Accept-Encoding
+	Precompressed bool
+
+	// validate structs in Request.Validate and Request.BindValid, register custom rules to it
+	Validator *Validator
+
+	// executed when handler or middleware panic with FieldErrors or
+	// ValidationErrors, respond 422 with the field errors in json by default
+	Unprocessable ErrorHandler
+
+	tree  *node
+	names map[string]*Route
+	scope *RouteGroup
+	// groups adding middleware, see matchGroup
+	groups []*RouteGroup
+	// router-wide middleware, see Use
+	ms []Mw
+	// handle wrapped by ms, composed by Use
+	handler HttpHandler
+	// strong etags of files without modify time, see etag
+	etags sync.Map
 }
 
 func beforeFile(_ *Response, _ *http.Request, _ string) bool {
 	return true
 }
 
+func notFound(r *Response, _ *Request) {
+	r.WithStatus(http.StatusNotFound).WithString(http.StatusText(http.StatusNotFound))
+}
+
+func methodNotAllowed(r *Response, _ *Request) {
+	r.WithStatus(http.StatusMethodNotAllowed)
+}
+
+// respond preflight requests of routes without an OPTIONS handler
+func preflight(r *Response, _ *Request) {
+	r.WithStatus(http.StatusOK)
+}
+
+// log the panic with request id and stack, and respond 500. the body and
+// headers set by the route before panic are dropped, headers set by router-wide
+// middleware like the request id are kept
+func onPanic(r *Response, req *Request, rcv interface{}) {
+	r.reset()
+	id := req.RequestID()
+	if id == "" {
+		id = "-"
+	}
+	log.Printf("httprouter: panic serving %s %s (request id %s): %v\n%s", req.Method, req.URL.Path, id, rcv, debug.Stack())
+	r.InternalError(errors.New(http.StatusText(http.StatusInternalServerError)))
+}
+
 // new router
 func NewRouter() *Router {
 	router := new(Router)
 	router.Tries = []int{API, PATHFILE, ENTRYFILE}
 	router.DocRoot = "."
 	router.EntryFile = "index.html"
-	router.configs = []config{}
-	router.ms = []Mw{}
-	router.prefix = ""
+	router.tree = newNode()
+	router.names = make(map[string]*Route)
+	router.scope = newRouteGroup(router, "", []Mw{})
+	router.handler = router.handle
 	router.BeforePathFile = beforeFile
 	router.BeforeEntryFile = beforeFile
+	router.PanicHandler = onPanic
+	router.NotFound = notFound
+	router.MethodNotAllowed = methodNotAllowed
+	router.Compressor = NewCompressor()
+	router.Symlinks = SymlinkInRoot
+	router.ContentTypes = NewContentTypes()
+	router.IndexFiles = []string{"index.html"}
+	router.Validator = NewValidator()
+	router.Unprocessable = unprocessable
 	return router
 }
 
@@ -67,187 +163,359 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.Flush(req)
 }
 
-func (router *Router) HandleRequest(w http.ResponseWriter, req *http.Request) *Response {
-	r := NewResponse(w)
-	if req.Method == http.MethodGet {
-		router.try(r, req)
+func (router *Router) HandleRequest(w http.ResponseWriter, req *http.Request) (r *Response) {
+	r = NewResponse(w).WithCompressor(router.Compressor).WithContentTypes(router.ContentTypes)
+	wreq := newRequest(req)
+	wreq.validator = router.Validator
+	defer removeMultipart(wreq, req)
+	// panics of router-wide middleware
+	defer router.recover(r, wreq)
+	router.handler(r, wreq)
+	return r
+}
+
+// route the request to handlers, files, or NotFound
+func (router *Router) handle(r *Response, req *Request) {
+	// recovered here so router-wide middleware see the response of PanicHandler
+	defer router.recover(r, req)
+	if len(r.headers) > 0 {
+		r.kept = r.headers.Clone()
+	}
+	var handled bool
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		handled = router.try(r, req)
 	} else {
-		router.tryApi(r, req)
+		handled = router.tryApi(r, req)
 	}
-	return r
+	if !handled && router.NotFound != nil {
+		router.notFound(req.URL.Path)(r, req)
+	}
+}
+
+// NotFound wrapped by middleware of the group with the longest prefix matching p
+func (router *Router) notFound(p string) HttpHandler {
+	g := router.matchGroup(p)
+	if g == nil {
+		return router.NotFound
+	}
+	return g.notFound.get(func() HttpHandler {
+		// NotFound is read per request, it may be set after groups
+		return chain(g.ms, func(r *Response, req *Request) {
+			router.NotFound(r, req)
+		})
+	})
+}
+
+// the group with the longest prefix matching path, the group created first
+// wins when prefixes are equal
+func (router *Router) matchGroup(p string) *RouteGroup {
+	var found *RouteGroup
+	for _, g := range router.groups {
+		if found != nil && len(g.prefix) <= len(found.prefix) {
+			continue
+		}
+		if prefix := strings.TrimSuffix(g.prefix, "/"); prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			found = g
+		}
+	}
+	return found
+}
+
+// add middleware around every request, including static files, NotFound and MethodNotAllowed
+func (router *Router) Use(ms ...Mw) {
+	router.ms = append(router.ms, ms...)
+	router.handler = chain(router.ms, router.handle)
 }
 
-func (router *Router) try(r *Response, req *http.Request) {
+// remove temp files of multipart form parsed through req. net/http only cleans
+// up the form of the original request, and req.Request is a copy of it
+func removeMultipart(req *Request, orig *http.Request) {
+	if f := req.MultipartForm; f != nil && f != orig.MultipartForm {
+		f.RemoveAll()
+	}
+}
+
+// recover from panic in handlers and middleware, http.ErrAbortHandler is
+// panicked again to abort the response as net/http does
+func (router *Router) recover(r *Response, req *Request) {
+	rcv := recover()
+	if rcv == nil {
+		return
+	}
+	if rcv == http.ErrAbortHandler || router.PanicHandler == nil {
+		panic(rcv)
+	}
+	if err, ok := rcv.(error); ok && isUnprocessable(err) && router.Unprocessable != nil {
+		router.Unprocessable(r, req, err)
+		return
+	}
+	router.PanicHandler(r, req, rcv)
+}
+
+func (router *Router) try(r *Response, req *Request) bool {
 	for _, try := range router.Tries {
 		switch try {
 		case API:
 			if router.tryApi(r, req) {
-				return
+				return true
 			}
 		case PATHFILE:
 			if router.tryPathFile(r, req) {
-				return
+				return true
 			}
 		case ENTRYFILE:
 			if router.tryEntryFile(r, req) {
-				return
+				return true
 			}
 		}
 	}
+	return false
 }
 
-func (router *Router) tryApi(r *Response, req *http.Request) bool {
-	methodNotAllowed := false
-	for _, conf := range router.configs {
-		matched, params := restfulpath.NewPath(conf.path).Match(req.URL.Path)
-		if !matched {
-			continue
-		}
-		if req.Method != conf.method {
-			methodNotAllowed = true
-			continue
-		}
-		bag := NewBagt()
-		for k, v := range params {
-			bag.Set(k, v)
+func (router *Router) tryApi(r *Response, req *Request) bool {
+	conf, values, matched := router.tree.lookup(req.Method, req.URL.Path)
+	if !matched {
+		return false
+	}
+	if conf == nil && req.Method == http.MethodHead {
+		conf, values, _ = router.tree.lookup(http.MethodGet, req.URL.Path)
+	}
+	if conf == nil {
+		routes := router.tree.matches(req.URL.Path)
+		r.WithHeader("Allow", strings.Join(allowed(routes), ", "))
+		// middleware like CORS see preflight requests the same as 405
+		if req.Method == http.MethodOptions {
+			routes[0].options.get(func() HttpHandler {
+				return chain(routes[0].ms, preflight)
+			})(r, req)
+			return true
 		}
-		wreq := &Request{bag, req}
-		for _, mid := range conf.ms {
-			if !mid.Before(r, wreq) {
-				return true
-			}
-			defer mid.After(r, wreq)
+		if router.MethodNotAllowed != nil {
+			routes[0].notAllowed.get(func() HttpHandler {
+				// MethodNotAllowed is read per request, it may be set after routes
+				return chain(routes[0].ms, func(r *Response, req *Request) {
+					router.MethodNotAllowed(r, req)
+				})
+			})(r, req)
 		}
-		conf.call(r, wreq)
-
-		return true
-	}
-	if methodNotAllowed {
-		r.WithStatus(http.StatusMethodNotAllowed)
 		return true
 	}
+	req.route = conf
+	req.setParams(conf.keys, values)
+	conf.handler(r, req)
 
-	return false
+	return true
 }
 
-func (router *Router) tryEntryFile(r *Response, req *http.Request) bool {
-	return router.tryFile(r, req, router.EntryFile, router.BeforeEntryFile)
+// methods allowed by routes, GET implies HEAD and OPTIONS is always allowed
+func allowed(routes []*Route) []string {
+	set := map[string]bool{http.MethodOptions: true}
+	for _, route := range routes {
+		set[route.method] = true
+		if route.method == http.MethodGet {
+			set[http.MethodHead] = true
+		}
+	}
+	methods := []string{}
+	for method := range set {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
 }
 
-func (router *Router) tryPathFile(r *Response, req *http.Request) bool {
-	return router.tryFile(r, req, req.URL.Path, router.BeforePathFile)
+func (router *Router) tryEntryFile(r *Response, req *Request) bool {
+	return router.tryFile(r, req, router.EntryFile, router.BeforeEntryFile, false)
 }
 
-func (router *Router) tryFile(r *Response, req *http.Request, file string, beforeFile onFileHandler) bool {
-	pathfile := Join(router.DocRoot, file)
-	if stat, err := os.Stat(pathfile); err != nil {
-		if os.IsNotExist(err) {
-			r.WithStatus(404).WithString("File Not Found")
-			return false
-		}
-	} else if stat.IsDir() {
-		r.WithStatus(404).WithString("File Not Found")
+func (router *Router) tryPathFile(r *Response, req *Request) bool {
+	return router.tryFile(r, req, req.URL.Path, router.BeforePathFile, true)
+}
+
+// serve file, when it's a directory and dir is true, serve its index file or listing
+func (router *Router) tryFile(r *Response, req *Request, file string, beforeFile onFileHandler, dir bool) bool {
+	name, err := router.clean(file)
+	if err != nil {
+		return false
+	}
+	fsys := router.fileSystem()
+	stat, err := fs.Stat(fsys, name)
+	if err != nil {
 		return false
 	}
+	if stat.IsDir() {
+		return dir && router.tryDir(r, req, fsys, name)
+	}
+	return router.serveFile(r, req, fsys, name, stat, beforeFile)
+}
+
+func (router *Router) serveFile(r *Response, req *Request, fsys fs.FS, name string, stat fs.FileInfo, beforeFile onFileHandler) bool {
 	r.WithStatus(200)
-	if beforeFile(r, req, pathfile) {
-		r.WithFile(pathfile)
+	if cc := router.cacheControl(name); cc != "" {
+		r.WithHeader("Cache-Control", cc)
+	}
+	if !beforeFile(r, req.Request, name) {
+		return true
+	}
+	file, encoding := name, ""
+	if router.Precompressed {
+		addVary(r.Headers(), "Accept-Encoding")
+		if f, enc, s := precompressed(req.Request, fsys, name); enc != "" {
+			file, encoding, stat = f, enc, s
+		}
+	}
+	if router.notModified(r, req.Request, fsys, file, stat) {
+		r.WithStatus(http.StatusNotModified)
+		return true
 	}
+	if encoding == "" {
+		r.WithFSFile(fsys, file)
+		return true
+	}
+	body, err := fsys.Open(file)
+	if err != nil {
+		return false
+	}
+	// type of the original file, and on the fly compression is skipped for the encoding
+	r.withFile(body, name)
+	r.WithHeader("Content-Encoding", encoding)
 	return true
 }
 
 // on get uri
-func (router *Router) OnGet(path string, h HttpHandler) {
-	router.Get(path, h)
+func (router *Router) OnGet(path string, h HttpHandler) *Route {
+	return router.Get(path, h)
 }
 
 // on post uri
-func (router *Router) OnPost(path string, h HttpHandler) {
-	router.Post(path, h)
+func (router *Router) OnPost(path string, h HttpHandler) *Route {
+	return router.Post(path, h)
 }
 
 // on put uri
-func (router *Router) OnPut(path string, h HttpHandler) {
-	router.Put(path, h)
+func (router *Router) OnPut(path string, h HttpHandler) *Route {
+	return router.Put(path, h)
 }
 
 // on delete uri
-func (router *Router) OnDelete(path string, h HttpHandler) {
-	router.Delete(path, h)
+func (router *Router) OnDelete(path string, h HttpHandler) *Route {
+	return router.Delete(path, h)
 }
 
 // on patch uri
-func (router *Router) OnPatch(path string, h HttpHandler) {
-	router.Patch(path, h)
+func (router *Router) OnPatch(path string, h HttpHandler) *Route {
+	return router.Patch(path, h)
 }
 
 // on connect uri
-func (router *Router) OnConnect(path string, h HttpHandler) {
-	router.Connect(path, h)
+func (router *Router) OnConnect(path string, h HttpHandler) *Route {
+	return router.Connect(path, h)
 }
 
 // on option uri
-func (router *Router) OnOption(path string, h HttpHandler) {
-	router.Option(path, h)
+func (router *Router) OnOption(path string, h HttpHandler) *Route {
+	return router.Option(path, h)
 }
 
 // on trace uri
-func (router *Router) OnTrace(path string, h HttpHandler) {
-	router.OnTrace(path, h)
+func (router *Router) OnTrace(path string, h HttpHandler) *Route {
+	return router.Trace(path, h)
 }
 
 // legacy on get uri, abandon future
-func (router *Router) Get(path string, h HttpHandler) {
-	router.Handle(http.MethodGet, path, h)
+func (router *Router) Get(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodGet, path, h)
 }
 
 // legacy on post uri, abandon future
-func (router *Router) Post(path string, h HttpHandler) {
-	router.Handle(http.MethodPost, path, h)
+func (router *Router) Post(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodPost, path, h)
 }
 
 // legacy on put uri, abandon future
-func (router *Router) Put(path string, h HttpHandler) {
-	router.Handle(http.MethodPut, path, h)
+func (router *Router) Put(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodPut, path, h)
 }
 
 // legacy on delete uri, abandon future
-func (router *Router) Delete(path string, h HttpHandler) {
-	router.Handle(http.MethodDelete, path, h)
+func (router *Router) Delete(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodDelete, path, h)
 }
 
 // legacy on patch uri, abandon future
-func (router *Router) Patch(path string, h HttpHandler) {
-	router.Handle(http.MethodPatch, path, h)
+func (router *Router) Patch(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodPatch, path, h)
 }
 
 // legacy on options uri, abandon future
-func (router *Router) Option(path string, h HttpHandler) {
-	router.Handle(http.MethodOptions, path, h)
+func (router *Router) Option(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodOptions, path, h)
 }
 
 // legacy on trace uri, abandon future
-func (router *Router) Trace(path string, h HttpHandler) {
-	router.Handle(http.MethodTrace, path, h)
+func (router *Router) Trace(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodTrace, path, h)
 }
 
 // legacy on connect uri, abandon future
-func (router *Router) Connect(path string, h HttpHandler) {
-	router.Handle(http.MethodConnect, path, h)
+func (router *Router) Connect(path string, h HttpHandler) *Route {
+	return router.Handle(http.MethodConnect, path, h)
+}
+
+// handle a request, panic when the route is invalid or conflict with a registered one
+func (router *Router) Handle(method string, path string, h HttpHandler) *Route {
+	return router.scope.Handle(method, path, h)
+}
+
+// handle a request, return error when the route is invalid or conflict with a registered one
+func (router *Router) HandleE(method string, path string, h HttpHandler) (*Route, error) {
+	return router.scope.HandleE(method, path, h)
+}
+
+// route requests under prefix to a net/http handler with the prefix stripped, like
+//
+//	router.Mount("/metrics", promhttp.Handler())
+func (router *Router) Mount(prefix string, h http.Handler) {
+	router.scope.Mount(prefix, h)
+}
+
+func (router *Router) register(method string, pattern string, ms []Mw, h HttpHandler) (*Route, error) {
+	if method == "" {
+		return nil, fmt.Errorf("%w %q: empty method", ErrInvalidPattern, pattern)
+	}
+	if h == nil {
+		return nil, fmt.Errorf("%w %s %s: nil handler", ErrInvalidPattern, method, pattern)
+	}
+	if err := validatePattern(pattern); err != nil {
+		return nil, err
+	}
+	leaf := router.tree.insert(pattern)
+	if exist, ok := leaf.routes[method]; ok {
+		if exist.path == pattern {
+			return nil, fmt.Errorf("%w: %s %s", ErrDuplicateRoute, method, pattern)
+		}
+		return nil, fmt.Errorf("%w: %s %s conflicts with %s", ErrAmbiguousRoute, method, pattern, exist.path)
+	}
+	route := &Route{router: router, method: method, path: pattern, keys: patternKeys(pattern), ms: ms, call: h}
+	route.handler = chain(ms, h)
+	leaf.routes[method] = route
+
+	return route, nil
 }
 
-// handle a request
-func (router *Router) Handle(method string, path string, h HttpHandler) {
-	router.configs = append(
-		router.configs, config{method, router.prefix + path, router.ms, h},
-	)
+// add prefix, middleware for a bunch of request. the group is scoped lexically,
+// routes registered in grp or through the returned group get the prefix and
+// middleware, and routes registered after grp returns don't
+func (router *Router) Group(prefix string, ms []Mw, grp GroupCall) *RouteGroup {
+	return router.scope.Group(prefix, ms, grp)
 }
 
-// add prefix, middleware for a bunch of request
-func (router *Router) Group(prefix string, ms []Mw, grp GroupCall) {
-	router.ms = mergeMiddleware(router.ms, ms)
-	router.prefix += prefix
+// call grp with registrations scoped to g, restore the previous scope afterwards
+func (router *Router) within(g *RouteGroup, grp GroupCall) {
+	outer := router.scope
+	router.scope = g
+	defer func() {
+		router.scope = outer
+	}()
 	grp(router)
-	router.ms = []Mw{}
-	router.prefix = ""
 }
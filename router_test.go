@@ -1,9 +1,18 @@
 package httprouter
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	. "testing"
 )
 
@@ -121,6 +130,20 @@ func getRequest(method string, path string) *http.Request {
 	return req
 }
 
+// serve a request with header by router, header can be nil
+func serveRequest(router *Router, method, p string, header http.Header) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req := getRequest(method, p)
+	req.Header = make(http.Header)
+	for key, vals := range header {
+		for _, val := range vals {
+			req.Header.Add(key, val)
+		}
+	}
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
 func TestRoute(t *T) {
 	writer := getWriter()
 	router.ServeHTTP(writer, getRequest("GET", "/hello-world"))
@@ -155,3 +178,609 @@ func TestRoute(t *T) {
 		t.Error("default router fail")
 	}
 }
+
+func TestRoutePriority(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	matched := ""
+	r.OnGet("/files/*filepath", func(w *Response, req *Request) {
+		matched = "catchall:" + req.Bag.Get("filepath").(string)
+	})
+	r.OnGet("/files/:name", func(w *Response, req *Request) {
+		matched = "param:" + req.Bag.Get("name").(string)
+	})
+	r.OnGet("/files/readme", func(w *Response, req *Request) {
+		matched = "static"
+	})
+	r.OnPost("/files/upload", func(w *Response, req *Request) {
+		matched = "upload"
+	})
+	cases := map[string]string{
+		"/files/readme":    "static",
+		"/files/logo.png":  "param:logo.png",
+		"/files/upload":    "param:upload",
+		"/files/img/a.png": "catchall:img/a.png",
+	}
+	for p, expect := range cases {
+		matched = ""
+		r.ServeHTTP(getWriter(), getRequest("GET", p))
+		if matched != expect {
+			t.Errorf("%s: expect %s, got %s", p, expect, matched)
+		}
+	}
+}
+
+func BenchmarkRoute(b *B) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	h := func(w *Response, req *Request) {}
+	for i := 0; i < 600; i++ {
+		r.OnGet(fmt.Sprintf("/api/v%d/users/:id/articles", i), h)
+	}
+	req := getRequest("GET", "/api/v599/users/42/articles")
+	writer := getWriter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.HandleRequest(writer, req)
+	}
+}
+
+type noopMw struct{}
+
+func (noopMw) Before(_ *Response, _ *Request) bool {
+	return true
+}
+
+func (noopMw) After(_ *Response, _ *Request) bool {
+	return true
+}
+
+// middleware chains are composed on register, not per request
+func BenchmarkRouteMiddleware(b *B) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.Use(noopMw{}, noopMw{})
+	h := func(w *Response, req *Request) {}
+	r.Group("/api", []Mw{noopMw{}, noopMw{}}, func(router *Router) {
+		for i := 0; i < 600; i++ {
+			router.OnGet(fmt.Sprintf("/v%d/users/:id/articles", i), h)
+		}
+	})
+	req := getRequest("GET", "/api/v599/users/42/articles")
+	writer := getWriter()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.HandleRequest(writer, req)
+	}
+}
+
+func TestRouteConflict(t *T) {
+	r := NewRouter()
+	h := func(w *Response, req *Request) {}
+	if _, err := r.HandleE("GET", "/users/:id", h); err != nil {
+		t.Errorf("register /users/:id: %s", err)
+	}
+	if _, err := r.HandleE("POST", "/users/:name", h); err != nil {
+		t.Errorf("register POST /users/:name: %s", err)
+	}
+	cases := []struct {
+		method string
+		path   string
+		err    error
+	}{
+		{"GET", "/users/:id", ErrDuplicateRoute},
+		{"GET", "/users/:name", ErrAmbiguousRoute},
+		{"", "/users", ErrInvalidPattern},
+		{"GET", "users", ErrInvalidPattern},
+		{"GET", "/users/:", ErrInvalidPattern},
+		{"GET", "/:id/:id", ErrInvalidPattern},
+		{"GET", "/files/*path/raw", ErrInvalidPattern},
+	}
+	for _, c := range cases {
+		if _, err := r.HandleE(c.method, c.path, h); !errors.Is(err, c.err) {
+			t.Errorf("%s %s: expect %v, got %v", c.method, c.path, c.err, err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Handle should panic on duplicate route")
+		}
+	}()
+	r.OnGet("/users/:id", h)
+}
+
+// middleware records its name into the bag for test
+type nameMw struct {
+	name string
+}
+
+func (mid *nameMw) Before(_ *Response, req *Request) bool {
+	chain, _ := req.Bag.Get("chain").(string)
+	req.Bag.Set("chain", chain+mid.name)
+	return true
+}
+
+func (mid *nameMw) After(_ *Response, _ *Request) bool {
+	return true
+}
+
+func TestNestedGroup(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	chains := make(map[string]string)
+	record := func(w *Response, req *Request) {
+		chain, _ := req.Bag.Get("chain").(string)
+		chains[req.URL.Path] = chain
+	}
+	r.Group("/api", []Mw{&nameMw{"a"}}, func(router *Router) {
+		router.OnGet("/before", record)
+		router.Group("/v1", []Mw{&nameMw{"b"}}, func(router *Router) {
+			router.OnGet("/inner", record)
+		})
+		router.OnGet("/after", record)
+	})
+	admin := r.Group("/admin", []Mw{&nameMw{"c"}}, nil)
+	admin.OnGet("/users", record)
+	admin.Group("/posts", []Mw{&nameMw{"d"}}, nil).OnGet("/:id", record)
+	r.OnGet("/root", record)
+
+	expects := map[string]string{
+		"/api/before":    "a",
+		"/api/v1/inner":  "ab",
+		"/api/after":     "a",
+		"/admin/users":   "c",
+		"/admin/posts/1": "cd",
+		"/root":          "",
+	}
+	for p, expect := range expects {
+		r.ServeHTTP(getWriter(), getRequest("GET", p))
+		chain, ok := chains[p]
+		if !ok {
+			t.Errorf("%s not matched", p)
+			continue
+		}
+		if chain != expect {
+			t.Errorf("%s: expect middleware %q, got %q", p, expect, chain)
+		}
+	}
+}
+
+type traceMw struct {
+	name  string
+	trace *[]string
+}
+
+func (mid *traceMw) Before(_ *Response, _ *Request) bool {
+	*mid.trace = append(*mid.trace, mid.name+">")
+	return mid.name != "stop"
+}
+
+func (mid *traceMw) After(_ *Response, _ *Request) bool {
+	*mid.trace = append(*mid.trace, "<"+mid.name)
+	return true
+}
+
+func TestMiddlewareFunc(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	trace := []string{}
+	onion := func(name string) MiddlewareFunc {
+		return func(next HttpHandler) HttpHandler {
+			return func(w *Response, req *Request) {
+				trace = append(trace, name+">")
+				next(w, req)
+				trace = append(trace, "<"+name)
+			}
+		}
+	}
+	// replace the response after the handler
+	teapot := MiddlewareFunc(func(next HttpHandler) HttpHandler {
+		return func(w *Response, req *Request) {
+			next(w, req)
+			if w.StatusCode() == http.StatusOK {
+				w.WithStatus(http.StatusTeapot)
+			}
+		}
+	})
+	h := func(w *Response, req *Request) {
+		trace = append(trace, "h")
+	}
+	r.Group("/api", []Mw{&traceMw{"a", &trace}, onion("b")}, func(router *Router) {
+		router.Group("/v1", []Mw{&traceMw{"c", &trace}, onion("d"), teapot}, func(router *Router) {
+			router.OnGet("/inner", h)
+		})
+		router.Group("/stop", []Mw{onion("c"), &traceMw{"stop", &trace}}, func(router *Router) {
+			router.OnGet("/inner", h)
+		})
+	})
+	r.OnGet("/wrapped", WrapMw(&traceMw{"e", &trace})(h))
+
+	expects := []struct {
+		path  string
+		trace string
+		code  int
+	}{
+		{"/api/v1/inner", "a> b> c> d> h <d <c <b <a", http.StatusTeapot},
+		{"/api/stop/inner", "a> b> c> stop> <c <b <a", http.StatusOK},
+		{"/wrapped", "e> h <e", http.StatusOK},
+	}
+	for _, expect := range expects {
+		trace = trace[:0]
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, getRequest("GET", expect.path))
+		if got := strings.Join(trace, " "); got != expect.trace {
+			t.Errorf("%s: expect trace %q, got %q", expect.path, expect.trace, got)
+		}
+		if w.Code != expect.code {
+			t.Errorf("%s: expect status %d, got %d", expect.path, expect.code, w.Code)
+		}
+	}
+}
+
+func TestRouteURL(t *T) {
+	r := NewRouter()
+	h := func(w *Response, req *Request) {}
+	r.Group("/api", []Mw{}, func(router *Router) {
+		router.OnGet("/users/:user_id", h).Name("user.show")
+	})
+	r.OnGet("/files/*filepath", h).Name("file")
+
+	u, err := r.URL("user.show", map[string]string{"user_id": "a b/c"})
+	if err != nil || u != "/api/users/a%20b%2Fc" {
+		t.Errorf("user.show: got %q, %v", u, err)
+	}
+	u, err = r.URL("file", map[string]string{"filepath": "img/a b.png"})
+	if err != nil || u != "/files/img/a%20b.png" {
+		t.Errorf("file: got %q, %v", u, err)
+	}
+	if _, err = r.URL("user.show", map[string]string{}); !errors.Is(err, ErrMissingParam) {
+		t.Errorf("missing param: got %v", err)
+	}
+	if _, err = r.URL("user.list", nil); !errors.Is(err, ErrRouteNotFound) {
+		t.Errorf("unknown name: got %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Name should panic on duplicate name")
+		}
+	}()
+	r.OnPost("/users", h).Name("user.show")
+}
+
+func TestHeadAndOptions(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.OnGet("/users/:id", func(w *Response, req *Request) {
+		w.WithString("user")
+	})
+	r.OnPut("/users/:id", func(w *Response, req *Request) {})
+	r.OnDelete("/users/admin", func(w *Response, req *Request) {})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("HEAD", "/users/1"))
+	if rec.Code != 200 || rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("head: got %d, %q, %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("OPTIONS", "/users/admin"))
+	if rec.Code != 200 || rec.Header().Get("Allow") != "DELETE, GET, HEAD, OPTIONS, PUT" {
+		t.Errorf("options: got %d, %q", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("POST", "/users/1"))
+	if rec.Code != 405 || rec.Header().Get("Allow") != "GET, HEAD, OPTIONS, PUT" {
+		t.Errorf("not allowed: got %d, %q", rec.Code, rec.Header().Get("Allow"))
+	}
+
+	// preflight goes through middleware of the group
+	cors := MiddlewareFunc(func(next HttpHandler) HttpHandler {
+		return func(w *Response, req *Request) {
+			w.WithHeader("Access-Control-Allow-Origin", "*")
+			next(w, req)
+		}
+	})
+	r.Group("/api", []Mw{cors}, func(router *Router) {
+		router.OnPost("/x", func(w *Response, req *Request) {})
+	})
+	rec = serveRequest(r, "OPTIONS", "/api/x", nil)
+	if rec.Code != 200 || rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Allow") != "OPTIONS, POST" {
+		t.Errorf("options with middleware: got %d, %v", rec.Code, rec.Header())
+	}
+}
+
+// middleware panics in After for test
+type panicMw struct{}
+
+func (mid *panicMw) Before(_ *Response, _ *Request) bool {
+	return true
+}
+
+func (mid *panicMw) After(_ *Response, _ *Request) bool {
+	panic("after")
+}
+
+func TestPanicHandler(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.OnGet("/panic", func(w *Response, req *Request) {
+		panic(errors.New("boom"))
+	})
+	r.Group("", []Mw{&panicMw{}}, func(router *Router) {
+		router.OnGet("/after", func(w *Response, req *Request) {})
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("GET", "/panic"))
+	if rec.Code != 500 {
+		t.Errorf("default panic handler: got %d", rec.Code)
+	}
+
+	var recovered interface{}
+	r.PanicHandler = func(w *Response, req *Request, rcv interface{}) {
+		recovered = rcv
+		w.WithStatus(503)
+	}
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("GET", "/after"))
+	if rec.Code != 503 || recovered != "after" {
+		t.Errorf("custom panic handler: got %d, %v", rec.Code, recovered)
+	}
+}
+
+func TestPanicAfterFile(t *T) {
+	p := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(p, []byte("a,b\n1,2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.Use(RequestID(nil))
+	var f *os.File
+	r.OnGet("/report", func(w *Response, req *Request) {
+		w.WithFile(p)
+		w.WithHeader("Content-Disposition", `attachment; filename="report.csv"`)
+		f, _ = w.Body().(*os.File)
+		panic("boom")
+	})
+
+	rec := serveRequest(r, "GET", "/report", http.Header{"X-Request-ID": {"p-1"}})
+	h := rec.Header()
+	if rec.Code != 500 || rec.Body.String() != "Internal Server Error" || h.Get("Content-Type") != "text/plain" {
+		t.Errorf("got %d, %q, %v", rec.Code, rec.Body.String(), h)
+	}
+	if h.Get("Content-Disposition") != "" || h.Get("Accept-Ranges") != "" || h.Get("X-Request-ID") != "p-1" {
+		t.Errorf("headers: got %v", h)
+	}
+	if f == nil {
+		t.Fatal("body is not the file")
+	}
+	if _, err := f.Stat(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("file is not closed: %v", err)
+	}
+}
+
+func TestNotFoundAndMethodNotAllowed(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	r.Group("/api", []Mw{&nameMw{"api"}}, func(router *Router) {
+		router.OnGet("/users", func(w *Response, req *Request) {})
+	})
+	r.Group("/api/v2", []Mw{&nameMw{"v2"}}, nil)
+	r.Group("/apis", []Mw{&nameMw{"apis"}}, nil)
+	var notFoundChain interface{}
+	r.NotFound = func(w *Response, req *Request) {
+		notFoundChain = req.Bag.Get("chain")
+		w.WithStatus(404).WithHeader("Content-Type", "application/json")
+		w.WithBody(strings.NewReader(`{"error":"not found"}`))
+	}
+	var chain interface{}
+	r.MethodNotAllowed = func(w *Response, req *Request) {
+		chain = req.Bag.Get("chain")
+		w.WithStatus(405).WithString("no")
+	}
+
+	for _, method := range []string{"GET", "POST"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, getRequest(method, "/api/articles"))
+		if rec.Code != 404 || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s not found: got %d, %v", method, rec.Code, rec.Header())
+		}
+	}
+	// middleware of the group with the longest matching prefix
+	for p, expect := range map[string]interface{}{"/api": "api", "/api/v2/users": "v2", "/apis/x": "apis", "/nope": nil} {
+		notFoundChain = nil
+		r.ServeHTTP(httptest.NewRecorder(), getRequest("GET", p))
+		if notFoundChain != expect {
+			t.Errorf("%s not found: expect chain %v, got %v", p, expect, notFoundChain)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, getRequest("DELETE", "/api/users"))
+	if rec.Code != 405 || rec.Header().Get("Allow") != "GET, HEAD, OPTIONS" || chain != "api" {
+		t.Errorf("not allowed: got %d, %q, %v", rec.Code, rec.Header().Get("Allow"), chain)
+	}
+}
+
+func TestMount(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	ext := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params := ParamsFromContext(req.Context())
+		chain, _ := BagFromContext(req.Context()).Get("chain").(string)
+		w.Header().Set("X-Chain", chain)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s %s", req.Method, req.URL.Path, params["id"])
+	})
+	r.Group("/users/:id", []Mw{&nameMw{"a"}}, func(router *Router) {
+		router.Mount("/ext/", ext)
+	})
+	r.OnGet("/std", WrapHandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("std"))
+	}))
+
+	expects := map[string]string{
+		"GET /users/7/ext":          "GET / 7",
+		"POST /users/7/ext/a/b/":    "POST /a/b/ 7",
+		"DELETE /users/8/ext/x?q=1": "DELETE /x 8",
+	}
+	for request, expect := range expects {
+		parts := strings.SplitN(request, " ", 2)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(parts[0], parts[1], nil))
+		if w.Code != http.StatusCreated || w.Body.String() != expect || w.Header().Get("X-Chain") != "a" {
+			t.Errorf("%s: got %d %q %v", request, w.Code, w.Body.String(), w.Header())
+		}
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, getRequest("GET", "/std"))
+	if w.Code != http.StatusOK || w.Body.String() != "std" {
+		t.Errorf("wrapped handler: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func TestWrapMiddleware(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	var status int
+	record := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rec := &statusRecorder{w, 0}
+			w.Header().Set("X-Std", "1")
+			next.ServeHTTP(rec, req.WithContext(context.WithValue(req.Context(), userKey, "alice")))
+			status = rec.status
+		})
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("Authorization") == "" {
+				http.Error(w, "denied", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+	r.Group("/api", []Mw{&nameMw{"a"}, WrapMiddleware(record), &nameMw{"b"}}, func(router *Router) {
+		router.OnGet("/users/:id", func(w *Response, req *Request) {
+			user, _ := userKey.Get(req)
+			chain, _ := req.Bag.Get("chain").(string)
+			w.WithStatus(http.StatusAccepted).WithString(user + " " + req.Param("id") + " " + chain)
+		})
+	})
+	r.Group("/private", []Mw{WrapMiddleware(deny)}, func(router *Router) {
+		router.OnGet("/", func(w *Response, req *Request) {
+			w.WithString("secret")
+		})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, getRequest("GET", "/api/users/7"))
+	if w.Code != http.StatusAccepted || w.Body.String() != "alice 7 ab" || w.Header().Get("X-Std") != "1" || status != http.StatusAccepted {
+		t.Errorf("std middleware: got %d %q %v, recorded %d", w.Code, w.Body.String(), w.Header(), status)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, getRequest("GET", "/private/"))
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "denied") {
+		t.Errorf("short circuit: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestUse(t *T) {
+	root, clean := makeDocRoot(t)
+	defer clean()
+	r := NewRouter()
+	r.Tries = []int{API, PATHFILE}
+	r.DocRoot = root
+	trace := []string{}
+	var status int
+	r.Use(&traceMw{"g", &trace}, MiddlewareFunc(func(next HttpHandler) HttpHandler {
+		return func(w *Response, req *Request) {
+			w.WithHeader("X-Frame-Options", "DENY")
+			next(w, req)
+			status = w.StatusCode()
+		}
+	}))
+	r.Group("/api", []Mw{&traceMw{"a", &trace}}, func(router *Router) {
+		router.OnGet("/users", func(w *Response, req *Request) {
+			trace = append(trace, "h")
+		})
+		router.OnGet("/panic", func(w *Response, req *Request) {
+			panic("boom")
+		})
+	})
+
+	expects := []struct {
+		method, path string
+		trace        string
+		code         int
+	}{
+		{"GET", "/api/users", "g> a> h <a <g", http.StatusOK},
+		{"GET", "/public.txt", "g> <g", http.StatusOK},
+		{"GET", "/nope", "g> <g", http.StatusNotFound},
+		{"POST", "/api/users", "g> a> <a <g", http.StatusMethodNotAllowed},
+		{"GET", "/api/panic", "g> a> <a <g", http.StatusInternalServerError},
+	}
+	for _, expect := range expects {
+		trace = trace[:0]
+		status = 0
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, getRequest(expect.method, expect.path))
+		if got := strings.Join(trace, " "); got != expect.trace {
+			t.Errorf("%s %s: expect trace %q, got %q", expect.method, expect.path, expect.trace, got)
+		}
+		if w.Code != expect.code || status != expect.code || w.Header().Get("X-Frame-Options") != "DENY" {
+			t.Errorf("%s %s: expect %d, got %d, seen %d, %v", expect.method, expect.path, expect.code, w.Code, status, w.Header())
+		}
+	}
+}
+
+func TestHijack(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API}
+	var hijackErr error
+	r.Mount("/ws", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			t.Error("mounted writer is not a http.Hijacker")
+			return
+		}
+		conn, buf, err := h.Hijack()
+		if err != nil {
+			hijackErr = err
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: test\r\nConnection: Upgrade\r\n\r\nhijacked")
+		buf.Flush()
+	}))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+	b, _ := ioutil.ReadAll(conn)
+	if !strings.HasPrefix(string(b), "HTTP/1.1 101 ") || !strings.HasSuffix(string(b), "\r\n\r\nhijacked") {
+		t.Errorf("hijacked response: got %q", b)
+	}
+
+	// the recorder can't be hijacked
+	if rec := serveRequest(r, "GET", "/ws", nil); !errors.Is(hijackErr, http.ErrNotSupported) || rec.Code != 200 {
+		t.Errorf("not hijackable: got %v", hijackErr)
+	}
+}
@@ -0,0 +1,233 @@
+package httprouter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// symlink policies of static files
+const (
+	// follow symlinks resolving inside DocRoot
+	SymlinkInRoot = iota
+	// follow all symlinks
+	SymlinkFollow
+	// never serve a file through symlink
+	SymlinkDeny
+)
+
+// clean url path into a name of the static file system. path with '..'
+// segments, backslashes or NUL, and hidden files (except .well-known) unless
+// ServeHidden are rejected with fs.ErrPermission
+func (router *Router) clean(p string) (string, error) {
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." || strings.ContainsAny(seg, "\\\x00") {
+			return "", fs.ErrPermission
+		}
+		if strings.HasPrefix(seg, ".") && seg != "." && seg != ".well-known" && !router.ServeHidden {
+			return "", fs.ErrPermission
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		return ".", nil
+	}
+	return name, nil
+}
+
+// file system of static files, FS if set, otherwise DocRoot
+func (router *Router) fileSystem() fs.FS {
+	if router.FS != nil {
+		return router.FS
+	}
+	return &docRoot{router.DocRoot, router.Symlinks}
+}
+
+// file system of a directory on disk, like os.DirFS but symlinks are checked with the policy
+type docRoot struct {
+	root     string
+	symlinks int
+}
+
+func (d *docRoot) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	p, err := d.resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return os.Open(p)
+}
+
+// resolve name into a file path under root, symlinks denied by the policy are
+// rejected with fs.ErrPermission
+func (d *docRoot) resolve(name string) (string, error) {
+	pathfile := filepath.Join(d.root, filepath.FromSlash(name))
+	if d.symlinks == SymlinkFollow {
+		return pathfile, nil
+	}
+	realRoot, err := filepath.EvalSymlinks(d.root)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(pathfile)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(realRoot, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fs.ErrPermission
+	}
+	if d.symlinks == SymlinkDeny {
+		if orig, err := filepath.Rel(d.root, pathfile); err != nil || orig != rel {
+			return "", fs.ErrPermission
+		}
+	}
+	return real, nil
+}
+
+// Cache-Control of static files whose name match Pattern, see path.Match. a
+// pattern without '/' match the base name, otherwise the whole name in the file system
+type CachePolicy struct {
+	Pattern      string
+	CacheControl string
+}
+
+func (p *CachePolicy) match(name string) bool {
+	if !strings.Contains(p.Pattern, "/") {
+		name = path.Base(name)
+	}
+	matched, _ := path.Match(p.Pattern, name)
+	return matched
+}
+
+// add a Cache-Control policy of static files, panic when pattern is malformed
+//
+//	router.CacheControl("*.[0-9a-f]*.js", "public, max-age=31536000, immutable")
+//	router.CacheControl(router.EntryFile, "no-cache")
+func (router *Router) CacheControl(pattern, cacheControl string) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Errorf("cache policy %q: %w", pattern, err))
+	}
+	router.CachePolicies = append(router.CachePolicies, CachePolicy{pattern, cacheControl})
+}
+
+// Cache-Control of the static file, empty when no policy match
+func (router *Router) cacheControl(name string) string {
+	for _, p := range router.CachePolicies {
+		if p.match(name) {
+			return p.CacheControl
+		}
+	}
+	return ""
+}
+
+// key of strong etags cached by router
+type etagKey struct {
+	fsys fs.FS
+	name string
+}
+
+// entity tag of the file. a weak one from modify time and size, or a strong
+// one from content when the file system has no modify time like embed.FS.
+// such file systems are immutable, so the strong one is hashed once and cached
+// when the file system can be a map key
+func (router *Router) etag(fsys fs.FS, name string, stat fs.FileInfo) string {
+	if !stat.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().UnixNano(), stat.Size())
+	}
+	cacheable := reflect.TypeOf(fsys).Comparable()
+	key := etagKey{fsys, name}
+	if cacheable {
+		if tag, ok := router.etags.Load(key); ok {
+			return tag.(string)
+		}
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	tag := fmt.Sprintf(`"%x"`, h.Sum(nil)[:16])
+	if cacheable {
+		router.etags.Store(key, tag)
+	}
+	return tag
+}
+
+// set validators of the file and check them against the conditional headers
+// of req, If-Modified-Since is ignored when If-None-Match is present
+func (router *Router) notModified(r *Response, req *http.Request, fsys fs.FS, name string, stat fs.FileInfo) bool {
+	tag := router.etag(fsys, name, stat)
+	if tag != "" {
+		r.WithHeader("ETag", tag)
+	}
+	modtime := stat.ModTime()
+	if !modtime.IsZero() {
+		r.WithHeader("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return tag != "" && matchETag(inm, tag)
+	}
+	if ims := req.Header.Get("If-Modified-Since"); ims != "" && !modtime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !modtime.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// weak comparison of tag and a list of entity tags, '*' match any
+func matchETag(list, tag string) bool {
+	tag = strings.TrimPrefix(tag, "W/")
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "*" || strings.TrimPrefix(item, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// extensions of precompressed siblings by content coding, preferred in order when accepted equally
+var precompressedExts = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"zstd", ".zst"},
+	{"gzip", ".gz"},
+}
+
+// the precompressed sibling of name the client accept most, encoding is empty when there's none
+func precompressed(req *http.Request, fsys fs.FS, name string) (file, encoding string, stat fs.FileInfo) {
+	accepts := parseAcceptEncoding(req.Header.Get("Accept-Encoding"))
+	best := 0.0
+	for _, pc := range precompressedExts {
+		q, ok := accepts[pc.encoding]
+		if !ok {
+			q = accepts["*"]
+		}
+		if q <= best {
+			continue
+		}
+		s, err := fs.Stat(fsys, name+pc.ext)
+		if err != nil || s.IsDir() {
+			continue
+		}
+		file, encoding, stat, best = name+pc.ext, pc.encoding, s, q
+	}
+	return
+}
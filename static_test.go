@@ -0,0 +1,355 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	. "testing"
+	"testing/fstest"
+	"time"
+)
+
+// make a doc root with public files, a hidden file and symlinks, and a secret file outside of it
+func makeDocRoot(t *T) (string, func()) {
+	dir, err := ioutil.TempDir("", "httprouter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "root")
+	files := map[string]string{
+		"secret.txt":                 "secret",
+		"root/index.html":            "index",
+		"root/public.txt":            "public",
+		"root/.env":                  "env",
+		"root/.well-known/security":  "security",
+		"root/%2e%2e/secret.txt":     "literal",
+		"root/assets/css/style.css":  "style",
+		"root/assets/js/app.min.js":  "app",
+		"root/assets/img/sprite.svg": "svg",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "escape.txt"))
+	os.Symlink(filepath.Join(root, "public.txt"), filepath.Join(root, "inside.txt"))
+	return root, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func serveFile(router *Router, p string) *httptest.ResponseRecorder {
+	return serveRequest(router, "GET", p, nil)
+}
+
+func TestHostilePaths(t *T) {
+	root, clean := makeDocRoot(t)
+	defer clean()
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	r.DocRoot = root
+
+	cases := map[string]int{
+		"/public.txt":               200,
+		"/.well-known/security":     200,
+		"/%2e%2e/secret.txt":        200,
+		"/inside.txt":               200,
+		"/../secret.txt":            404,
+		"/assets/../../secret.txt":  404,
+		"..":                        404,
+		"/..\\secret.txt":           404,
+		"/public.txt\x00.html":      404,
+		"/.env":                     404,
+		"/assets/./../.env":         404,
+		"/escape.txt":               404,
+		"/assets":                   404,
+		"/not-exist.txt":            404,
+		"/assets/css/style.css":     200,
+		"//assets//js//app.min.js":  200,
+		"/assets/img/../sprite.svg": 404,
+	}
+	for p, code := range cases {
+		if rec := serveFile(r, p); rec.Code != code {
+			t.Errorf("%q: expect %d, got %d", p, code, rec.Code)
+		}
+	}
+
+	r.ServeHidden = true
+	if rec := serveFile(r, "/.env"); rec.Code != 200 {
+		t.Errorf("serve hidden: got %d", rec.Code)
+	}
+	r.Symlinks = SymlinkFollow
+	if rec := serveFile(r, "/escape.txt"); rec.Code != 200 {
+		t.Errorf("follow symlink: got %d", rec.Code)
+	}
+	r.Symlinks = SymlinkDeny
+	if rec := serveFile(r, "/inside.txt"); rec.Code != 404 {
+		t.Errorf("deny symlink: got %d", rec.Code)
+	}
+	if rec := serveFile(r, "/public.txt"); rec.Code != 200 {
+		t.Errorf("deny symlink, regular file: got %d", rec.Code)
+	}
+}
+
+func TestServeFS(t *T) {
+	r := NewRouter()
+	r.Tries = []int{API, PATHFILE, ENTRYFILE}
+	r.FS = fstest.MapFS{
+		"index.html":    {Data: []byte("<html>spa</html>")},
+		"assets/app.js": {Data: []byte("console.log(1)")},
+		".env":          {Data: []byte("secret")},
+	}
+	var served string
+	r.BeforePathFile = func(_ *Response, _ *http.Request, name string) bool {
+		served = name
+		return true
+	}
+	rec := serveFile(r, "/assets/app.js")
+	if rec.Code != 200 || rec.Body.String() != "console.log(1)" || served != "assets/app.js" {
+		t.Errorf("path file: got %d, %q, %q", rec.Code, rec.Body.String(), served)
+	}
+	rec = serveFile(r, "/users/42")
+	if rec.Code != 200 || rec.Body.String() != "<html>spa</html>" {
+		t.Errorf("entry file: got %d, %q", rec.Code, rec.Body.String())
+	}
+	r.Tries = []int{PATHFILE}
+	for _, p := range []string{"/.env", "/../index.html", "/assets"} {
+		if rec := serveFile(r, p); rec.Code != 404 {
+			t.Errorf("%q: expect 404, got %d", p, rec.Code)
+		}
+	}
+}
+
+func TestConditionalGet(t *T) {
+	modtime := time.Date(2020, 9, 17, 8, 0, 0, 0, time.UTC)
+	r := NewRouter()
+	r.FS = fstest.MapFS{
+		"index.html":           {Data: []byte("<html>spa</html>")},
+		"assets/app.1a2b3c.js": {Data: []byte("console.log(1)"), ModTime: modtime},
+	}
+	r.CacheControl("*.[0-9a-f]*.js", "public, max-age=31536000, immutable")
+	r.CacheControl(r.EntryFile, "no-cache")
+
+	rec := serveRequest(r, "GET", "/assets/app.1a2b3c.js", nil)
+	tag := rec.Header().Get("ETag")
+	if rec.Code != 200 || tag == "" || rec.Header().Get("Last-Modified") != "Thu, 17 Sep 2020 08:00:00 GMT" {
+		t.Fatalf("validators: got %d, %v", rec.Code, rec.Header())
+	}
+	if rec.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("hashed asset cache control: got %q", rec.Header().Get("Cache-Control"))
+	}
+	if rec = serveRequest(r, "GET", "/assets/app.1a2b3c.js", map[string][]string{"If-None-Match": {`"x", ` + tag}}); rec.Code != 304 || rec.Body.Len() != 0 {
+		t.Errorf("if none match: got %d", rec.Code)
+	}
+	if rec = serveRequest(r, "GET", "/assets/app.1a2b3c.js", map[string][]string{"If-Modified-Since": {"Thu, 17 Sep 2020 08:00:00 GMT"}}); rec.Code != 304 {
+		t.Errorf("if modified since: got %d", rec.Code)
+	}
+	if rec = serveRequest(r, "GET", "/assets/app.1a2b3c.js", map[string][]string{"If-Modified-Since": {"Wed, 16 Sep 2020 08:00:00 GMT"}}); rec.Code != 200 {
+		t.Errorf("modified: got %d", rec.Code)
+	}
+	if rec = serveRequest(r, "GET", "/assets/app.1a2b3c.js", map[string][]string{
+		"If-None-Match":     {`"x"`},
+		"If-Modified-Since": {"Thu, 17 Sep 2020 08:00:00 GMT"},
+	}); rec.Code != 200 {
+		t.Errorf("if none match take precedence: got %d", rec.Code)
+	}
+
+	rec = serveRequest(r, "GET", "/users/1", nil)
+	tag = rec.Header().Get("ETag")
+	if rec.Code != 200 || tag == "" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("entry file: got %d, %v", rec.Code, rec.Header())
+	}
+	if rec = serveRequest(r, "GET", "/users/2", map[string][]string{"If-None-Match": {tag}}); rec.Code != 304 {
+		t.Errorf("entry file if none match: got %d", rec.Code)
+	}
+}
+
+func TestContentTypes(t *T) {
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	r.FS = fstest.MapFS{
+		"app.js":      {Data: []byte("console.log(1)")},
+		"logo.svg":    {Data: []byte("<svg></svg>")},
+		"PHOTO.WEBP":  {Data: []byte("RIFF")},
+		"data.custom": {Data: []byte("{}")},
+		"blob":        {Data: []byte("\x89PNG\x0D\x0A\x1A\x0A rest of image")},
+		"notes":       {Data: []byte("plain notes")},
+	}
+	r.ContentTypes.Add(".CUSTOM", "application/x-custom")
+	cases := map[string]string{
+		"/app.js":      "text/javascript; charset=utf-8",
+		"/logo.svg":    "image/svg+xml",
+		"/PHOTO.WEBP":  "image/webp",
+		"/data.custom": "application/x-custom",
+		"/blob":        "image/png",
+		"/notes":       "text/plain; charset=utf-8",
+	}
+	for p, expect := range cases {
+		rec := serveFile(r, p)
+		if ct := rec.Header().Get("Content-Type"); ct != expect {
+			t.Errorf("%s: expect %q, got %q", p, expect, ct)
+		}
+	}
+	if rec := serveFile(r, "/blob"); rec.Body.String() != "\x89PNG\x0D\x0A\x1A\x0A rest of image" {
+		t.Errorf("sniffed body: got %q", rec.Body.String())
+	}
+}
+
+func TestDirectory(t *T) {
+	modtime := time.Date(2020, 9, 17, 8, 0, 0, 0, time.UTC)
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	r.FS = fstest.MapFS{
+		"docs/index.html":         {Data: []byte("docs")},
+		"x?y/index.html":          {Data: []byte("q")},
+		"a%b#c/index.html":        {Data: []byte("p")},
+		"d:e/index.html":          {Data: []byte("c")},
+		"artifacts/b.tar":         {Data: []byte("bb"), ModTime: modtime},
+		"artifacts/a<script>.zip": {Data: []byte("aaa"), ModTime: modtime.Add(time.Hour)},
+		"artifacts/nightly/c.tar": {Data: []byte("c")},
+		"artifacts/.hidden":       {Data: []byte("h")},
+	}
+
+	rec := serveFile(r, "/docs/")
+	if rec.Code != 200 || rec.Body.String() != "docs" {
+		t.Errorf("index: got %d, %q", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	req := getRequest("GET", "/docs")
+	req.URL.RawQuery = "v=1"
+	r.ServeHTTP(rec, req)
+	if rec.Code != 301 || rec.Header().Get("Location") != "docs/?v=1" {
+		t.Errorf("redirect: got %d, %q", rec.Code, rec.Header().Get("Location"))
+	}
+	escapes := map[string]string{
+		"/x?y":   "x%3Fy/",
+		"/a%b#c": "a%25b%23c/",
+		"/d:e":   "./d:e/",
+	}
+	for p, expect := range escapes {
+		if rec = serveFile(r, p); rec.Code != 301 || rec.Header().Get("Location") != expect {
+			t.Errorf("redirect %s: expect %q, got %d, %q", p, expect, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+	if rec = serveFile(r, "/artifacts/"); rec.Code != 404 {
+		t.Errorf("listing disabled: got %d", rec.Code)
+	}
+
+	r.DirListing = true
+	list := func(query string) []dirEntry {
+		rec := httptest.NewRecorder()
+		req := getRequest("GET", "/artifacts/")
+		req.URL.RawQuery = query
+		r.ServeHTTP(rec, req)
+		entries := []dirEntry{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+		return entries
+	}
+	names := func(entries []dirEntry) string {
+		s := []string{}
+		for _, e := range entries {
+			s = append(s, e.Name)
+		}
+		return strings.Join(s, ",")
+	}
+	cases := map[string]string{
+		"format=json":                      "nightly,a<script>.zip,b.tar",
+		"format=json&sort=size":            "nightly,b.tar,a<script>.zip",
+		"format=json&sort=time&order=desc": "nightly,a<script>.zip,b.tar",
+		"format=json&sort=name&order=desc": "nightly,b.tar,a<script>.zip",
+	}
+	for query, expect := range cases {
+		if got := names(list(query)); got != expect {
+			t.Errorf("%s: expect %s, got %s", query, expect, got)
+		}
+	}
+
+	rec = serveFile(r, "/artifacts/")
+	body := rec.Body.String()
+	if rec.Code != 200 || !strings.Contains(body, "a&lt;script&gt;.zip") || strings.Contains(body, "<script>") || strings.Contains(body, ".hidden") {
+		t.Errorf("html listing: got %d, %s", rec.Code, body)
+	}
+}
+
+func TestPrecompressed(t *T) {
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	r.Precompressed = true
+	r.FS = fstest.MapFS{
+		"app.js":    {Data: []byte(strings.Repeat("console.log(1);", 100))},
+		"app.js.gz": {Data: []byte("gzipped")},
+		"app.js.br": {Data: []byte("brotli")},
+		"app.css":   {Data: []byte("body{}")},
+	}
+	cases := []struct {
+		acceptEncoding string
+		encoding       string
+		body           string
+	}{
+		{"gzip, deflate, br", "br", "brotli"},
+		{"gzip", "gzip", "gzipped"},
+		{"br;q=0.5, gzip", "gzip", "gzipped"},
+		{"", "", strings.Repeat("console.log(1);", 100)},
+	}
+	for _, c := range cases {
+		rec := serveRequest(r, "GET", "/app.js", http.Header{"Accept-Encoding": {c.acceptEncoding}})
+		h := rec.Header()
+		if h.Get("Content-Encoding") != c.encoding || rec.Body.String() != c.body {
+			t.Errorf("%q: got %q, %q", c.acceptEncoding, h.Get("Content-Encoding"), rec.Body.String())
+		}
+		if h.Get("Content-Type") != "text/javascript; charset=utf-8" || len(h.Values("Vary")) != 1 {
+			t.Errorf("%q: got headers %v", c.acceptEncoding, h)
+		}
+	}
+	rec := serveRequest(r, "GET", "/app.css", http.Header{"Accept-Encoding": {"br"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "body{}" {
+		t.Errorf("no sibling: got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	// off by default
+	r = NewRouter()
+	r.Tries = []int{PATHFILE}
+	r.FS = fstest.MapFS{"backup.tar": {Data: []byte("tar")}, "backup.tar.gz": {Data: []byte("gzipped")}}
+	rec = serveRequest(r, "GET", "/backup.tar", http.Header{"Accept-Encoding": {"gzip"}})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "tar" {
+		t.Errorf("default: got %v, %q", rec.Header(), rec.Body.String())
+	}
+}
+
+// file system counting opens of each file
+type countFS struct {
+	fs.FS
+	opens map[string]int
+}
+
+func (c *countFS) Open(name string) (fs.File, error) {
+	c.opens[name]++
+	return c.FS.Open(name)
+}
+
+func TestETagCache(t *T) {
+	r := NewRouter()
+	r.Tries = []int{PATHFILE}
+	fsys := &countFS{fstest.MapFS{"movie.mp4": {Data: []byte("0123456789")}}, map[string]int{}}
+	r.FS = fsys
+
+	tag := serveFile(r, "/movie.mp4").Header().Get("ETag")
+	first := fsys.opens["movie.mp4"]
+	if rec := serveFile(r, "/movie.mp4"); rec.Header().Get("ETag") != tag {
+		t.Errorf("etag: expect %q, got %q", tag, rec.Header().Get("ETag"))
+	}
+	// the file is hashed only for the first response
+	if second := fsys.opens["movie.mp4"] - first; second != first-1 {
+		t.Errorf("expect %d opens of the second response, got %d", first-1, second)
+	}
+}
@@ -1,5 +1,7 @@
 package httprouter
 
+import "net/http"
+
 var std *Router
 
 func init() {
@@ -8,34 +10,46 @@ func init() {
 func Handler() *Router {
 	return std
 }
-func OnPost(path string, h HttpHandler) {
-	std.OnPost(path, h)
+func OnPost(path string, h HttpHandler) *Route {
+	return std.OnPost(path, h)
+}
+
+func OnPut(path string, h HttpHandler) *Route {
+	return std.OnPut(path, h)
+}
+
+func OnDelete(path string, h HttpHandler) *Route {
+	return std.OnDelete(path, h)
+}
+
+func OnGet(path string, h HttpHandler) *Route {
+	return std.OnGet(path, h)
 }
 
-func OnPut(path string, h HttpHandler) {
-	std.OnPut(path, h)
+func OnOption(path string, h HttpHandler) *Route {
+	return std.OnOption(path, h)
 }
 
-func OnDelete(path string, h HttpHandler) {
-	std.OnDelete(path, h)
+func OnPatch(path string, h HttpHandler) *Route {
+	return std.OnPatch(path, h)
 }
 
-func OnGet(path string, h HttpHandler) {
-	std.OnGet(path, h)
+func OnConnect(path string, h HttpHandler) *Route {
+	return std.OnConnect(path, h)
 }
 
-func OnOption(path string, h HttpHandler) {
-	std.OnOption(path, h)
+func Group(prefix string, ms []Mw, grp GroupCall) *RouteGroup {
+	return std.Group(prefix, ms, grp)
 }
 
-func OnPatch(path string, h HttpHandler) {
-	std.OnPatch(path, h)
+func URL(name string, params map[string]string) (string, error) {
+	return std.URL(name, params)
 }
 
-func OnConnect(path string, h HttpHandler) {
-	std.OnConnect(path, h)
+func Mount(prefix string, h http.Handler) {
+	std.Mount(prefix, h)
 }
 
-func Group(prefix string, ms []Mw, grp GroupCall) {
-	std.Group(prefix, ms, grp)
+func Use(ms ...Mw) {
+	std.Use(ms...)
 }
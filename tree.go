@@ -0,0 +1,186 @@
+package httprouter
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// pattern of route is malformed
+	ErrInvalidPattern = errors.New("invalid route pattern")
+	// same method and pattern registered twice
+	ErrDuplicateRoute = errors.New("duplicate route")
+	// same method and a pattern that match exactly the same paths with another one
+	ErrAmbiguousRoute = errors.New("ambiguous route")
+)
+
+// node of the route tree. routes are split by '/' and every segment is a level of
+// the tree, a segment is either static, a :param or a *catchall, and lookup
+// always prefer static over param over catchall
+type node struct {
+	statics  map[string]*node
+	param    *node
+	catchAll *node
+	routes   map[string]*Route
+}
+
+func newNode() *node {
+	n := new(node)
+	n.statics = make(map[string]*node)
+	n.routes = make(map[string]*Route)
+	return n
+}
+
+func isParam(seg string) bool {
+	return strings.HasPrefix(seg, ":")
+}
+
+func isCatchAll(seg string) bool {
+	return strings.HasPrefix(seg, "*")
+}
+
+// split path into segments, "/users/:id" will be ["", "users", ":id"]
+func splitPath(p string) []string {
+	return strings.Split(p, "/")
+}
+
+// param names of a pattern in order of appearance
+func patternKeys(pattern string) []string {
+	keys := []string{}
+	for _, seg := range splitPath(pattern) {
+		if isParam(seg) || isCatchAll(seg) {
+			keys = append(keys, seg[1:])
+		}
+	}
+	return keys
+}
+
+// check pattern is well formed, it must start with '/', params and catchalls
+// must be named uniquely, and a catchall can only be the last segment
+func validatePattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("%w %q: must start with '/'", ErrInvalidPattern, pattern)
+	}
+	segs := splitPath(pattern)
+	names := make(map[string]bool)
+	for i, seg := range segs {
+		if !isParam(seg) && !isCatchAll(seg) {
+			continue
+		}
+		name := seg[1:]
+		if name == "" {
+			return fmt.Errorf("%w %q: empty param name", ErrInvalidPattern, pattern)
+		}
+		if names[name] {
+			return fmt.Errorf("%w %q: param %s appears more than once", ErrInvalidPattern, pattern, name)
+		}
+		names[name] = true
+		if isCatchAll(seg) && i != len(segs)-1 {
+			return fmt.Errorf("%w %q: catchall must be the last segment", ErrInvalidPattern, pattern)
+		}
+	}
+	return nil
+}
+
+// insert pattern into tree, return the leaf node of the pattern
+func (n *node) insert(pattern string) *node {
+	cur := n
+	for _, seg := range splitPath(pattern) {
+		switch {
+		case isParam(seg):
+			if cur.param == nil {
+				cur.param = newNode()
+			}
+			cur = cur.param
+		case isCatchAll(seg):
+			if cur.catchAll == nil {
+				cur.catchAll = newNode()
+			}
+			// catchall eat the rest of the path
+			return cur.catchAll
+		default:
+			child, ok := cur.statics[seg]
+			if !ok {
+				child = newNode()
+				cur.statics[seg] = child
+			}
+			cur = child
+		}
+	}
+	return cur
+}
+
+// find the route for method and path. values are the param values in order of
+// the route keys. when matched is true and conf is nil, the path matched some
+// routes but none of them accept the method
+func (n *node) lookup(method, p string) (conf *Route, values []string, matched bool) {
+	return n.find(method, splitPath(p), []string{})
+}
+
+func (n *node) find(method string, segs []string, values []string) (conf *Route, vals []string, matched bool) {
+	if len(segs) == 0 {
+		if len(n.routes) == 0 {
+			return nil, nil, false
+		}
+		if c, ok := n.routes[method]; ok {
+			return c, values, true
+		}
+		return nil, nil, true
+	}
+	seg := segs[0]
+	if child, ok := n.statics[seg]; ok {
+		c, v, m := child.find(method, segs[1:], values)
+		if c != nil {
+			return c, v, true
+		}
+		matched = matched || m
+	}
+	if n.param != nil {
+		c, v, m := n.param.find(method, segs[1:], append(values, seg))
+		if c != nil {
+			return c, v, true
+		}
+		matched = matched || m
+	}
+	if n.catchAll != nil && len(n.catchAll.routes) > 0 {
+		if c, ok := n.catchAll.routes[method]; ok {
+			return c, append(values, strings.Join(segs, "/")), true
+		}
+		matched = true
+	}
+	return nil, nil, matched
+}
+
+// all routes matching path, in priority order of the tree and sorted by method in the same node
+func (n *node) matches(p string) []*Route {
+	routes := []*Route{}
+	n.collect(splitPath(p), func(leaf *node) {
+		methods := []string{}
+		for method := range leaf.routes {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			routes = append(routes, leaf.routes[method])
+		}
+	})
+	return routes
+}
+
+func (n *node) collect(segs []string, visit func(*node)) {
+	if len(segs) == 0 {
+		visit(n)
+		return
+	}
+	if child, ok := n.statics[segs[0]]; ok {
+		child.collect(segs[1:], visit)
+	}
+	if n.param != nil {
+		n.param.collect(segs[1:], visit)
+	}
+	if n.catchAll != nil {
+		visit(n.catchAll)
+	}
+}
@@ -0,0 +1,333 @@
+package httprouter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// rule check value of a field with the param of the rule, param is "1" for
+// min=1 and empty for required. value is dereferenced when the field is a pointer
+type Rule func(value interface{}, param string) error
+
+// error of a field failing a rule
+type ValidationError struct {
+	// name of the struct field, nested fields are joined by '.'
+	Field string
+	// key of the field in request, from its json, form, query or param tag
+	Key   string
+	Rule  string
+	Param string
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Field, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// errors of fields failing rules
+type ValidationErrors []*ValidationError
+
+func (errs ValidationErrors) Error() string {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// validate structs by rules in validate tag like `validate:"required,min=1,max=100"`.
+// rules other than required are skipped when the field is zero
+type Validator struct {
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+// new validator with rules required, min, max, len, email, url and oneof
+func NewValidator() *Validator {
+	v := &Validator{rules: make(map[string]Rule)}
+	v.rules["required"] = ruleRequired
+	v.rules["min"] = ruleMin
+	v.rules["max"] = ruleMax
+	v.rules["len"] = ruleLen
+	v.rules["email"] = ruleEmail
+	v.rules["url"] = ruleURL
+	v.rules["oneof"] = ruleOneOf
+	return v
+}
+
+var defaultValidator = NewValidator()
+
+// register a rule, it overrides the built-in one with the same name
+func (v *Validator) Register(name string, rule Rule) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rules[name] = rule
+}
+
+func (v *Validator) rule(name string) (Rule, bool) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	rule, ok := v.rules[name]
+	return rule, ok
+}
+
+// validate the struct s or s points to, fields failing rules are reported with
+// ValidationErrors, and other errors mean the tags are malformed
+func (v *Validator) Validate(s interface{}) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.New("validate target must be a struct or a pointer to struct")
+	}
+	errs := ValidationErrors{}
+	if err := v.validateStruct(rv, "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *Validator) validateStruct(rv reflect.Value, prefix string, errs *ValidationErrors) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := rv.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+		if tag != "" {
+			if err := v.validateField(fv, field, prefix, tag, errs); err != nil {
+				return err
+			}
+		}
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct && !fv.Type().Implements(textUnmarshalerType) && !reflect.PtrTo(fv.Type()).Implements(textUnmarshalerType) {
+			if err := v.validateStruct(fv, prefix+field.Name+".", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *Validator) validateField(fv reflect.Value, field reflect.StructField, prefix, tag string, errs *ValidationErrors) error {
+	type named struct {
+		name, param string
+		rule        Rule
+	}
+	rules := []named{}
+	required := false
+	for _, r := range strings.Split(tag, ",") {
+		name, param := strings.TrimSpace(r), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, param = name[:i], name[i+1:]
+		}
+		if name == "" {
+			continue
+		}
+		rule, ok := v.rule(name)
+		if !ok {
+			return fmt.Errorf("unknown validation rule %q of field %s", name, prefix+field.Name)
+		}
+		required = required || name == "required"
+		rules = append(rules, named{name, param, rule})
+	}
+	if !required && fv.IsZero() {
+		return nil
+	}
+	value := fv
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	for _, r := range rules {
+		var err error
+		if r.name == "required" {
+			err = r.rule(fv.Interface(), r.param)
+		} else if value.Kind() == reflect.Ptr {
+			continue
+		} else {
+			err = r.rule(value.Interface(), r.param)
+		}
+		if err != nil {
+			*errs = append(*errs, &ValidationError{prefix + field.Name, fieldKey(field), r.name, r.param, err})
+			// later rules make no sense on a missing value
+			if r.name == "required" {
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// key of field in request, from the first of json, form, query and param tags
+func fieldKey(field reflect.StructField) string {
+	for _, tag := range []string{"json", "form", "query", "param"} {
+		if key := strings.Split(field.Tag.Get(tag), ",")[0]; key != "" && key != "-" {
+			return key
+		}
+	}
+	return field.Name
+}
+
+func ruleRequired(value interface{}, _ string) error {
+	rv := reflect.ValueOf(value)
+	if !rv.IsValid() || rv.IsZero() {
+		return errors.New("is required")
+	}
+	if (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Map) && rv.Len() == 0 {
+		return errors.New("is required")
+	}
+	return nil
+}
+
+// number of the value to compare with min and max, length for strings, slices and maps
+func measure(value interface{}) (float64, string, error) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(rv.String())), "length", nil
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(rv.Len()), "length", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), "value", nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), "value", nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), "value", nil
+	}
+	return 0, "", fmt.Errorf("can't measure %T", value)
+}
+
+func compare(value interface{}, param string, ok func(n, limit float64) bool, msg string) error {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Errorf("invalid rule param %q", param)
+	}
+	n, what, err := measure(value)
+	if err != nil {
+		return err
+	}
+	if !ok(n, limit) {
+		return fmt.Errorf("%s must be %s %s", what, msg, param)
+	}
+	return nil
+}
+
+func ruleMin(value interface{}, param string) error {
+	return compare(value, param, func(n, limit float64) bool { return n >= limit }, "at least")
+}
+
+func ruleMax(value interface{}, param string) error {
+	return compare(value, param, func(n, limit float64) bool { return n <= limit }, "at most")
+}
+
+func ruleLen(value interface{}, param string) error {
+	return compare(value, param, func(n, limit float64) bool { return n == limit }, "exactly")
+}
+
+func ruleEmail(value interface{}, _ string) error {
+	s := fmt.Sprint(value)
+	if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+		return errors.New("must be an email address")
+	}
+	return nil
+}
+
+func ruleURL(value interface{}, _ string) error {
+	u, err := url.ParseRequestURI(fmt.Sprint(value))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return errors.New("must be an absolute url")
+	}
+	return nil
+}
+
+// param is space separated choices like oneof=asc desc
+func ruleOneOf(value interface{}, param string) error {
+	s := fmt.Sprint(value)
+	for _, choice := range strings.Fields(param) {
+		if s == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be one of %s", strings.Join(strings.Fields(param), ", "))
+}
+
+// validate dst with the validator of router
+func (req *Request) Validate(dst interface{}) error {
+	v := req.validator
+	if v == nil {
+		v = defaultValidator
+	}
+	return v.Validate(dst)
+}
+
+// bind request into dst then validate it. panic with the error in handler
+// and Router.Unprocessable responds it with 422
+func (req *Request) BindValid(dst interface{}) error {
+	if err := req.Bind(dst); err != nil {
+		return err
+	}
+	return req.Validate(dst)
+}
+
+// field error in 422 responses
+type unprocessableField struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// is err from binding or validating fields, which should be responded with 422
+func isUnprocessable(err error) bool {
+	var fes FieldErrors
+	var ves ValidationErrors
+	return errors.As(err, &fes) || errors.As(err, &ves)
+}
+
+// respond 422 with a json body like
+//
+//	{"message": "Unprocessable Entity", "errors": [{"field": "page", "rule": "min", "message": "value must be at least 1"}]}
+func unprocessable(r *Response, _ *Request, err error) {
+	fields := []unprocessableField{}
+	var fes FieldErrors
+	var ves ValidationErrors
+	if errors.As(err, &fes) {
+		for _, fe := range fes {
+			fields = append(fields, unprocessableField{fe.Key, "type", fe.Err.Error()})
+		}
+	}
+	if errors.As(err, &ves) {
+		for _, ve := range ves {
+			fields = append(fields, unprocessableField{ve.Key, ve.Rule, ve.Err.Error()})
+		}
+	}
+	body, _ := json.Marshal(map[string]interface{}{
+		"message": http.StatusText(http.StatusUnprocessableEntity),
+		"errors":  fields,
+	})
+	r.WithStatus(http.StatusUnprocessableEntity).WithHeader("Content-Type", "application/json")
+	r.WithBody(strings.NewReader(string(body)))
+}